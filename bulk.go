@@ -0,0 +1,238 @@
+package bstore
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+)
+
+// BulkLoad inserts all values from seq as new records of type T in transaction
+// tx, which must be writable. It is meant for large imports, and is faster than
+// calling Insert for each value.
+//
+// Default values and autoincrement primary keys are assigned as with Insert,
+// but the assigned primary keys are not returned. Records are written in
+// primary key order with a high bolt page fill percentage. Index keys are
+// gathered while reading values, and inserted in sorted order afterwards.
+// Unique, reference and nonzero constraints are verified for all values. References
+// are verified after all values have been written, so values can reference other
+// values in the same bulk load. All records and index keys are kept in memory
+// until they are written.
+//
+// Hooks set with SetHooks are not called for bulk loaded records.
+//
+// On error, tx is marked as botched and cannot be committed anymore, e.g.
+// ErrUnique for a duplicate primary key or unique index value, ErrZero for
+// nonzero constraint violations, and ErrReference for references to absent
+// records.
+//
+// The number of inserted records is returned.
+func BulkLoad[T any](tx *Tx, seq iter.Seq[T]) (n int, rerr error) {
+	if err := tx.error(); err != nil {
+		return 0, err
+	}
+	if !tx.btx.Writable() {
+		return 0, fmt.Errorf("%w: bulk load requires writable transaction", ErrParam)
+	}
+	if seq == nil {
+		return 0, fmt.Errorf("%w: nil seq", ErrParam)
+	}
+
+	var zero T
+	t := reflect.TypeOf(zero)
+	if t.Kind() != reflect.Struct {
+		return 0, fmt.Errorf("%w: type must be struct, not pointer or other type", ErrType)
+	}
+	st, err := tx.db.storeType(t)
+	if err != nil {
+		return 0, err
+	}
+	tv := st.Current
+
+	// Sequences may be changed while gathering records, and we may have
+	// written data when we fail.
+	defer tx.markError(&rerr)
+
+	rb, err := tx.recordsBucket(tv.name, tv.fillPercent)
+	if err != nil {
+		return 0, err
+	}
+	tx.stats.Records.Cursor++
+	emptyRecords, _ := rb.Cursor().First()
+
+	type record struct {
+		k, v []byte
+	}
+	var records []record
+
+	// Index keys per index, sorted and inserted after all records are written.
+	var idxs []*index
+	for _, idx := range tv.Indices {
+		idxs = append(idxs, idx)
+	}
+	type key struct {
+		buf []byte
+		pre int
+	}
+	ibkeys := make([][]key, len(idxs))
+
+	// References to verify at the end, with the referencing field and value for
+	// error messages.
+	type refSource struct {
+		field string
+		value any
+	}
+	refs := map[recordKey]refSource{}
+
+	ctxDone := tx.ctx.Done()
+	for value := range seq {
+		select {
+		case <-ctxDone:
+			return n, tx.ctx.Err()
+		default:
+		}
+
+		tx.stats.Insert++
+		rv := reflect.ValueOf(&value).Elem()
+		if err := tv.applyDefault(rv, tx.db.now); err != nil {
+			return n, err
+		}
+		tv.setAutoTimes(rv, reflect.Value{}, tx.db.now)
+		tv.initVersion(rv)
+		if err := tx.assignSeqs(tv, rv, true); err != nil {
+			return n, err
+		}
+		if err := st.validate(rv); err != nil {
+			return n, err
+		}
+		k, _, _, err := tv.keyValue(tx, rv, true, rb)
+		if err != nil {
+			return n, err
+		}
+		v, err := st.pack(rv)
+		if err != nil {
+			return n, err
+		}
+		records = append(records, record{k, v})
+
+		for i, idx := range idxs {
+			ikl, err := idx.packKey(rv, k)
+			if err != nil {
+				return n, fmt.Errorf("creating key for index %s.%s: %w", tv.name, idx.Name, err)
+			}
+			for _, ik := range ikl {
+				ibkeys[i] = append(ibkeys[i], key{ik.full, len(ik.pre)})
+			}
+		}
+
+		for _, f := range tv.Fields {
+			if len(f.References) == 0 {
+				continue
+			}
+			frv := rv.FieldByIndex(f.structField.Index)
+			if frv.IsZero() {
+				continue
+			}
+			rk, err := packPK(frv)
+			if err != nil {
+				return n, err
+			}
+			for _, name := range f.References {
+				refs[recordKey{name, string(rk)}] = refSource{f.Name, frv.Interface()}
+			}
+		}
+		n++
+	}
+
+	// Write records in primary key order, checking for duplicates in the loaded
+	// values and against existing records.
+	slices.SortFunc(records, func(a, b record) int {
+		return bytes.Compare(a.k, b.k)
+	})
+	rb.FillPercent = 1
+	defer func() {
+		rb.FillPercent = tv.fillPercent
+	}()
+	pkError := func(k []byte) error {
+		pkv := reflect.New(tv.Fields[0].structField.Type).Elem()
+		parsePK(pkv, k) // Ignore error, value is only informational.
+		return &ConstraintError{Err: ErrUnique, Type: tv.name, Field: tv.Fields[0].Name, Value: pkv.Interface()}
+	}
+	for i, r := range records {
+		if i > 0 && bytes.Equal(records[i-1].k, r.k) {
+			return n, pkError(r.k)
+		}
+		if emptyRecords != nil {
+			tx.stats.Records.Get++
+			if rb.Get(r.k) != nil {
+				return n, pkError(r.k)
+			}
+		}
+		tx.stats.Records.Put++
+		if err := rb.Put(r.k, r.v); err != nil {
+			return n, fmt.Errorf("%w: inserting record: %s", ErrStore, err)
+		}
+	}
+	records = nil
+	tx.bucketReseek(rb)
+
+	// Insert sorted index keys, checking unique constraints.
+	insertKeys := func(idx *index, keys []key) error {
+		ib, err := tx.indexBucket(idx)
+		if err != nil {
+			return err
+		}
+		tx.stats.Index.Cursor++
+		emptyIndex, _ := ib.Cursor().First()
+		fillPercent := ib.FillPercent
+		ib.FillPercent = 1
+		defer func() {
+			ib.FillPercent = fillPercent
+		}()
+		for i, k := range keys {
+			if idx.Unique {
+				pre := k.buf[:k.pre]
+				if i > 0 && bytes.Equal(keys[i-1].buf[:keys[i-1].pre], pre) {
+					return idx.uniqueKeyError(k.buf)
+				}
+				if emptyIndex != nil {
+					tx.stats.Index.Cursor++
+					if xk, _ := ib.Cursor().Seek(pre); xk != nil && bytes.HasPrefix(xk, pre) {
+						return idx.uniqueKeyError(k.buf)
+					}
+				}
+			}
+			tx.stats.Index.Put++
+			if err := ib.Put(k.buf, []byte{}); err != nil {
+				return fmt.Errorf("inserting into index %q: %w", idx.Name, err)
+			}
+		}
+		tx.bucketReseek(ib)
+		return nil
+	}
+	for i, idx := range idxs {
+		keys := ibkeys[i]
+		ibkeys[i] = nil
+		slices.SortFunc(keys, func(a, b key) int {
+			return bytes.Compare(a.buf, b.buf)
+		})
+		if err := insertKeys(idx, keys); err != nil {
+			return n, err
+		}
+	}
+
+	// Verify references, now that all records have been written.
+	for rk, src := range refs {
+		rrb, err := tx.recordsBucket(rk.typeName, tx.db.typeNames[rk.typeName].Current.fillPercent)
+		if err != nil {
+			return n, err
+		}
+		tx.stats.Records.Get++
+		if rrb.Get([]byte(rk.pk)) == nil {
+			return n, &ConstraintError{Err: ErrReference, Type: tv.name, Field: src.field, Value: src.value, Referenced: rk.typeName}
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,102 @@
+package bstore
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestBulkLoad(t *testing.T) {
+	type Group struct {
+		ID   int
+		Name string `bstore:"unique"`
+	}
+	type User struct {
+		ID       int
+		Name     string `bstore:"nonzero,index"`
+		GroupID  int    `bstore:"ref Group"`
+		ParentID int    `bstore:"ref User"`
+		Role     string `bstore:"default member"`
+	}
+
+	const path = "testdata/tmp.bulkload.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, Group{}, User{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	err = db.Insert(ctxbg, &Group{ID: 1, Name: "existing"})
+	tcheck(t, err, "insert group")
+
+	var users []User
+	for i := range 100 {
+		// Reverse order, and with references to records later in the load.
+		id := 100 - i
+		u := User{ID: id, Name: "user", GroupID: 1 + id%2}
+		if id < 100 {
+			u.ParentID = id + 1
+		}
+		users = append(users, u)
+	}
+	groups := []Group{{Name: "a"}, {Name: "b"}}
+
+	err = db.Write(ctxbg, func(tx *Tx) error {
+		n, err := BulkLoad(tx, slices.Values(groups))
+		tcompare(t, err, n, 2, "bulk load groups")
+		n, err = BulkLoad(tx, slices.Values(users))
+		tcompare(t, err, n, 100, "bulk load users")
+		return nil
+	})
+	tcheck(t, err, "write")
+
+	gl, err := QueryDB[Group](ctxbg, db).List()
+	tcompare(t, err, gl, []Group{{1, "existing"}, {2, "a"}, {3, "b"}}, "groups")
+
+	n, err := QueryDB[User](ctxbg, db).FilterEqual("Name", "user").FilterEqual("Role", "member").Count()
+	tcompare(t, err, n, 100, "users through index")
+	n, err = QueryDB[User](ctxbg, db).FilterEqual("GroupID", 2).Count()
+	tcompare(t, err, n, 50, "users through ref index")
+
+	// Autoincrement continues after the loaded records.
+	u := User{Name: "new"}
+	err = db.Insert(ctxbg, &u)
+	tcompare(t, err, u.ID, 101, "insert after bulk load")
+
+	load := func(l []User, expErr error, msg string) {
+		t.Helper()
+		err := db.Write(ctxbg, func(tx *Tx) error {
+			_, err := BulkLoad(tx, slices.Values(l))
+			tneed(t, err, expErr, msg)
+			err = tx.Insert(&User{Name: "x"})
+			tneed(t, err, ErrTxBotched, "insert after failed bulk load")
+			return nil
+		})
+		tneed(t, err, ErrTxBotched, "commit after failed bulk load")
+	}
+	load([]User{{ID: 200, Name: "x"}, {ID: 200, Name: "x"}}, ErrUnique, "duplicate pk in load")
+	load([]User{{ID: 1, Name: "x"}}, ErrUnique, "existing pk")
+	load([]User{{Name: "x"}, {}}, ErrZero, "nonzero")
+	load([]User{{Name: "x", GroupID: 10}}, ErrReference, "reference")
+	err = db.Write(ctxbg, func(tx *Tx) error {
+		_, err := BulkLoad(tx, slices.Values([]Group{{Name: "c"}, {Name: "c"}}))
+		tneed(t, err, ErrUnique, "duplicate unique in load")
+		return nil
+	})
+	tneed(t, err, ErrTxBotched, "write")
+	err = db.Write(ctxbg, func(tx *Tx) error {
+		_, err := BulkLoad(tx, slices.Values([]Group{{Name: "a"}}))
+		tneed(t, err, ErrUnique, "existing unique value")
+		return nil
+	})
+	tneed(t, err, ErrTxBotched, "write")
+
+	n, err = QueryDB[User](ctxbg, db).Count()
+	tcompare(t, err, n, 101, "users after failed loads")
+
+	err = db.Read(ctxbg, func(tx *Tx) error {
+		_, err := BulkLoad(tx, slices.Values(users))
+		tneed(t, err, ErrParam, "bulk load in read-only tx")
+		return nil
+	})
+	tcheck(t, err, "read")
+}
@@ -0,0 +1,277 @@
+package bstore
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// fieldCheck holds the value constraints of a field, from struct tags "enum",
+// "min", "max", "maxlen" and "pattern". Stored in the typeVersion.
+type fieldCheck struct {
+	Enum    []string `json:",omitempty"` // Allowed values for strings and integers. Integers are normalized to base 10.
+	Min     string   `json:",omitempty"` // Minimum value for integers and floats.
+	Max     string   `json:",omitempty"` // Maximum value for integers and floats.
+	MaxLen  int      `json:",omitempty"` // Maximum length: characters for strings, bytes for []byte, elements for slices and maps.
+	Pattern string   `json:",omitempty"` // Regular expression that strings must match.
+
+	// Parsed versions of the fields above. Only set for the current typeVersion
+	// linked to a Go type.
+	enum     map[string]struct{}
+	min, max any // int64, uint64 or float64, depending on kind.
+	pattern  *regexp.Regexp
+}
+
+// parseFieldCheck returns the value constraints from the struct tags, or nil if
+// there are none.
+func parseFieldCheck(tags storeTags, ft fieldType) (*fieldCheck, error) {
+	var c fieldCheck
+	var enum, maxlen string
+	words := []struct {
+		word string
+		ptr  *string
+	}{
+		{"enum", &enum},
+		{"min", &c.Min},
+		{"max", &c.Max},
+		{"maxlen", &maxlen},
+		{"pattern", &c.Pattern},
+	}
+	var have bool
+	for _, w := range words {
+		s, err := tags.Get(w.word)
+		if err != nil {
+			return nil, err
+		}
+		*w.ptr = s
+		have = have || s != ""
+	}
+	if !have {
+		return nil, nil
+	}
+	if enum != "" {
+		c.Enum = strings.Fields(enum)
+	}
+	if maxlen != "" {
+		v, err := strconv.ParseUint(maxlen, 10, 31)
+		if err != nil || v == 0 {
+			return nil, fmt.Errorf("%w: bad maxlen %q", ErrType, maxlen)
+		}
+		c.MaxLen = int(v)
+	}
+
+	if c.Min != "" || c.Max != "" {
+		var parse func(s string) (any, error)
+		switch ft.Kind {
+		case kindInt, kindInt8, kindInt16, kindInt32, kindInt64:
+			parse = func(s string) (any, error) { return strconv.ParseInt(s, 0, 64) }
+		case kindUint, kindUint8, kindUint16, kindUint32, kindUint64:
+			parse = func(s string) (any, error) { return strconv.ParseUint(s, 0, 64) }
+		case kindFloat32, kindFloat64:
+			parse = func(s string) (any, error) { return strconv.ParseFloat(s, 64) }
+		default:
+			return nil, fmt.Errorf("%w: only integers and floats can have min/max, not %s", ErrType, ft.Kind)
+		}
+		var err error
+		if c.Min != "" {
+			if c.min, err = parse(c.Min); err != nil {
+				return nil, fmt.Errorf("%w: bad min %q: %v", ErrType, c.Min, err)
+			}
+		}
+		if c.Max != "" {
+			if c.max, err = parse(c.Max); err != nil {
+				return nil, fmt.Errorf("%w: bad max %q: %v", ErrType, c.Max, err)
+			}
+		}
+	}
+
+	if c.Enum != nil {
+		c.enum = map[string]struct{}{}
+		for i, s := range c.Enum {
+			switch ft.Kind {
+			case kindString:
+			case kindInt, kindInt8, kindInt16, kindInt32, kindInt64:
+				v, err := strconv.ParseInt(s, 0, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%w: bad enum value %q", ErrType, s)
+				}
+				s = strconv.FormatInt(v, 10)
+			case kindUint, kindUint8, kindUint16, kindUint32, kindUint64:
+				v, err := strconv.ParseUint(s, 0, 64)
+				if err != nil {
+					return nil, fmt.Errorf("%w: bad enum value %q", ErrType, s)
+				}
+				s = strconv.FormatUint(v, 10)
+			default:
+				return nil, fmt.Errorf("%w: only strings and integers can have enum, not %s", ErrType, ft.Kind)
+			}
+			c.Enum[i] = s
+			c.enum[s] = struct{}{}
+		}
+	}
+
+	if c.MaxLen > 0 {
+		switch ft.Kind {
+		case kindString, kindBytes, kindSlice, kindMap:
+		default:
+			return nil, fmt.Errorf("%w: only strings, []byte, slices and maps can have maxlen, not %s", ErrType, ft.Kind)
+		}
+	}
+
+	if c.Pattern != "" {
+		if ft.Kind != kindString {
+			return nil, fmt.Errorf("%w: only strings can have pattern, not %s", ErrType, ft.Kind)
+		}
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad pattern %q: %v", ErrType, c.Pattern, err)
+		}
+		c.pattern = re
+	}
+	return &c, nil
+}
+
+// valid returns whether rv, of the field's Go type, satisfies the constraints.
+// Nil pointers are always valid.
+func (c *fieldCheck) valid(rv reflect.Value) bool {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return true
+		}
+		rv = rv.Elem()
+	}
+
+	if c.enum != nil {
+		var s string
+		switch rv.Kind() {
+		case reflect.String:
+			s = rv.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s = strconv.FormatInt(rv.Int(), 10)
+		default:
+			s = strconv.FormatUint(rv.Uint(), 10)
+		}
+		if _, ok := c.enum[s]; !ok {
+			return false
+		}
+	}
+
+	inRange := func(bound any, isMin bool) bool {
+		var r int
+		switch b := bound.(type) {
+		case int64:
+			r = cmp.Compare(rv.Int(), b)
+		case uint64:
+			r = cmp.Compare(rv.Uint(), b)
+		case float64:
+			r = cmp.Compare(rv.Float(), b)
+		}
+		if isMin {
+			return r >= 0
+		}
+		return r <= 0
+	}
+	if c.min != nil && !inRange(c.min, true) {
+		return false
+	}
+	if c.max != nil && !inRange(c.max, false) {
+		return false
+	}
+
+	if c.MaxLen > 0 {
+		n := rv.Len()
+		if rv.Kind() == reflect.String {
+			n = utf8.RuneCountInString(rv.String())
+		}
+		if n > c.MaxLen {
+			return false
+		}
+	}
+
+	if c.pattern != nil && !c.pattern.MatchString(rv.String()) {
+		return false
+	}
+	return true
+}
+
+// equal returns whether the stored constraints of c and nc are the same.
+func (c *fieldCheck) equal(nc *fieldCheck) bool {
+	if c == nil || nc == nil {
+		return c == nc
+	}
+	return slices.Equal(c.Enum, nc.Enum) && c.Min == nc.Min && c.Max == nc.Max && c.MaxLen == nc.MaxLen && c.Pattern == nc.Pattern
+}
+
+// newChecks returns whether nfields, possibly through nested struct fields,
+// have value constraints that were not present in ofields.
+func newChecks(ofields, nfields []field) bool {
+	for _, nf := range nfields {
+		var oft *fieldType
+		var ocheck *fieldCheck
+		for _, of := range ofields {
+			if of.Name == nf.Name {
+				oft = &of.Type
+				ocheck = of.Check
+				break
+			}
+		}
+		if nf.Check != nil && !nf.Check.equal(ocheck) {
+			return true
+		}
+		if newTypeChecks(oft, nf.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+func newTypeChecks(oft *fieldType, nft fieldType) bool {
+	if oft == nil {
+		oft = &fieldType{}
+	}
+	if len(nft.DefinitionFields) > 0 && newChecks(oft.DefinitionFields, nft.DefinitionFields) {
+		return true
+	}
+	if nft.ListElem != nil && newTypeChecks(oft.ListElem, *nft.ListElem) {
+		return true
+	}
+	if nft.MapValue != nil && newTypeChecks(oft.MapValue, *nft.MapValue) {
+		return true
+	}
+	return false
+}
+
+// checkRecords verifies that all records of st satisfy the constraints of the
+// current typeVersion, by packing them.
+func (tx *Tx) checkRecords(st storeType) error {
+	rb, err := tx.recordsBucket(st.Current.name, st.Current.fillPercent)
+	if err != nil {
+		return err
+	}
+
+	ctxDone := tx.ctx.Done()
+
+	return rb.ForEach(func(bk, bv []byte) error {
+		tx.stats.Records.Cursor++
+
+		select {
+		case <-ctxDone:
+			return tx.ctx.Err()
+		default:
+		}
+
+		rv, err := st.parseNew(bk, bv)
+		if err != nil {
+			return err
+		}
+		if _, err := st.pack(rv); err != nil {
+			return fmt.Errorf("existing record %v: %w", rv.Field(0).Interface(), err)
+		}
+		return nil
+	})
+}
@@ -41,12 +41,22 @@ func usage() {
 	log.Println("usage: bstore types file.db")
 	log.Println("       bstore drop file.db type")
 	log.Println("       bstore dumptype file.db type")
+	log.Println("       bstore schemas file.db type")
 	log.Println("       bstore keys file.db type")
 	log.Println("       bstore records file.db type")
+	log.Println("       bstore query file.db type query-json")
 	log.Println("       bstore record file.db type key")
 	log.Println("       bstore exportcsv file.db type >export.csv")
 	log.Println("       bstore exportjson [flags] file.db [type] >export.json")
 	log.Println("       bstore dumpall file.db")
+	log.Println("       bstore genfields [-o bstorefields.go] [-types Type1,Type2] dir")
+	log.Println("")
+	log.Println("query-json is a JSON object with Filters, Orders and Limit, e.g.:")
+	log.Println("")
+	log.Println(`	{"Filters": [{"Field": "Kind", "Op": "in", "Value": ["a", "b"]}], "Orders": [{"Field": "ID", "Desc": true}], "Limit": 10}`)
+	log.Println("")
+	log.Println(`Filter operations are "=", "!=", "<", "<=", ">", ">=" and "in".`)
+	log.Println("Times are strings in RFC3339 format.")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -69,10 +79,14 @@ func main() {
 		drop(args)
 	case "dumptype":
 		dumptype(args)
+	case "schemas":
+		schemas(args)
 	case "keys":
 		keys(args)
 	case "records":
 		records(args)
+	case "query":
+		query(args)
 	case "record":
 		record(args)
 	case "exportcsv":
@@ -81,6 +95,8 @@ func main() {
 		exportjson(args)
 	case "dumpall":
 		dumpall(args)
+	case "genfields":
+		genfields(args)
 	}
 }
 
@@ -164,6 +180,22 @@ func dumptype(args []string) {
 	xcheckf(err, "view tx")
 }
 
+func schemas(args []string) {
+	if len(args) != 2 {
+		usage()
+	}
+
+	db := xopen(args[0])
+	xdbread(db, func(tx *bstore.Tx) {
+		l, err := tx.Schemas(args[1])
+		xcheckf(err, "schemas")
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		err = enc.Encode(l)
+		xcheckf(err, "marshal schemas")
+	})
+}
+
 func keys(args []string) {
 	if len(args) != 2 {
 		usage()
@@ -194,6 +226,25 @@ func records(args []string) {
 	})
 }
 
+func query(args []string) {
+	if len(args) != 3 {
+		usage()
+	}
+
+	var q bstore.RecordQuery
+	err := json.Unmarshal([]byte(args[2]), &q)
+	xcheckf(err, "parsing query")
+
+	db := xopen(args[0])
+	xdbread(db, func(tx *bstore.Tx) {
+		var fields []string
+		err := tx.QueryRecords(args[1], q, &fields, func(v map[string]any) error {
+			return json.NewEncoder(os.Stdout).Encode(v)
+		})
+		xcheckf(err, "query")
+	})
+}
+
 func record(args []string) {
 	if len(args) != 3 {
 		usage()
@@ -416,11 +467,17 @@ func dumpall(args []string) {
 	xcheckf(err, "bolt open")
 	err = db.View(func(tx *bolt.Tx) error {
 		err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			if string(name) == ".bstore" {
+				// Named counters, not a type.
+				return nil
+			}
 			fmt.Println("#", string(name))
 			var indices []string
 			err := b.ForEach(func(bk, bv []byte) error {
 				if bytes.HasPrefix(bk, []byte("index.")) {
 					indices = append(indices, string(bk))
+				} else if bytes.HasPrefix(bk, []byte("seq.")) {
+					// Sequence for field with "seq" or "modseq" tag.
 				} else {
 					switch string(bk) {
 					case "records", "types":
@@ -6,11 +6,21 @@ Subcommands:
 	usage: bstore types file.db
 	       bstore drop file.db type
 	       bstore dumptype file.db type
+	       bstore schemas file.db type
 	       bstore keys file.db type
 	       bstore records file.db type
+	       bstore query file.db type query-json
 	       bstore record file.db type key
 	       bstore exportcsv file.db type >export.csv
 	       bstore exportjson [flags] file.db [type] >export.json
 	       bstore dumpall file.db
+	       bstore genfields [-o bstorefields.go] [-types Type1,Type2] dir
+
+	query-json is a JSON object with Filters, Orders and Limit, e.g.:
+
+		{"Filters": [{"Field": "Kind", "Op": "in", "Value": ["a", "b"]}], "Orders": [{"Field": "ID", "Desc": true}], "Limit": 10}
+
+	Filter operations are "=", "!=", "<", "<=", ">", ">=" and "in".
+	Times are strings in RFC3339 format.
 */
 package main
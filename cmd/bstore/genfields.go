@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// genfields generates a typed field name type with constants for the fields of
+// Go struct types stored with bstore, and functions calling the Query methods
+// that take field names as string, e.g. FilterEqual, SortAsc and UpdateField,
+// with those typed field names. The generated code also references each struct
+// field, so renaming or removing a field without generating the code again
+// results in compile errors, instead of runtime errors.
+func genfields(args []string) {
+	fs := flag.NewFlagSet("genfields", flag.ExitOnError)
+	output := fs.String("o", "bstorefields.go", "file to write generated code to, relative to dir; - for stdout")
+	typeList := fs.String("types", "", "comma-separated struct type names to generate field names for; by default all struct types with a bstore struct tag")
+	fs.Usage = usage
+	fs.Parse(args)
+	args = fs.Args()
+	if len(args) != 1 {
+		usage()
+	}
+	dir := args[0]
+
+	outPath := *output
+	if outPath != "-" {
+		outPath = filepath.Join(dir, outPath)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	xcheckf(err, "listing go files")
+
+	fset := token.NewFileSet()
+	var pkgName string
+	structs := map[string]*ast.StructType{}
+	var typeNames []string // In order of definition.
+	for _, p := range files {
+		if strings.HasSuffix(p, "_test.go") || p == outPath {
+			continue
+		}
+		f, err := parser.ParseFile(fset, p, nil, parser.SkipObjectResolution)
+		xcheckf(err, "parsing %s", p)
+		if pkgName == "" {
+			pkgName = f.Name.Name
+		} else if f.Name.Name != pkgName {
+			xcheckf(fmt.Errorf("package %s, expected %s", f.Name.Name, pkgName), "parsing %s", p)
+		}
+		// Only package-level types, not types declared in function bodies.
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok && ts.TypeParams == nil {
+					structs[ts.Name.Name] = st
+					typeNames = append(typeNames, ts.Name.Name)
+				}
+			}
+		}
+	}
+	if pkgName == "" {
+		xcheckf(fmt.Errorf("no go files"), "parsing package in %s", dir)
+	}
+
+	var selected []string
+	if *typeList != "" {
+		for _, name := range strings.Split(*typeList, ",") {
+			if structs[name] == nil {
+				xcheckf(fmt.Errorf("no struct type %q", name), "selecting types")
+			}
+			selected = append(selected, name)
+		}
+	} else {
+		for _, name := range typeNames {
+			if hasBstoreTag(structs[name]) {
+				selected = append(selected, name)
+			}
+		}
+	}
+	if len(selected) == 0 {
+		xcheckf(fmt.Errorf("no struct types with bstore struct tags, use -types"), "selecting types")
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by \"bstore genfields\"; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\nimport \"github.com/mjl-/bstore\"\n", pkgName)
+	for _, name := range selected {
+		var fields, embeds []genField
+		gatherGenFields(structs, structs[name], map[string]bool{name: true}, map[string]bool{}, &fields, &embeds)
+		err := genfieldsTemplate.Execute(&b, genType{
+			Type:           name,
+			Fields:         fields,
+			Embeds:         embeds,
+			FilterEqualOps: []string{"FilterEqual", "FilterNotEqual"},
+			FilterValueOps: []string{"FilterIn", "FilterGreater", "FilterGreaterEqual", "FilterLess", "FilterLessEqual"},
+			SortOps:        []string{"SortAsc", "SortDesc"},
+		})
+		xcheckf(err, "generating code for %s", name)
+	}
+
+	buf, err := format.Source(b.Bytes())
+	xcheckf(err, "formatting generated code")
+	if outPath == "-" {
+		_, err = os.Stdout.Write(buf)
+		xcheckf(err, "write")
+	} else {
+		err = os.WriteFile(outPath, buf, 0644)
+		xcheckf(err, "writing %s", outPath)
+	}
+}
+
+// genField is a field for which a constant is generated.
+type genField struct {
+	GoName string // Name of the Go struct field.
+	Name   string // Name of the field in bstore, different from GoName with a "name" struct tag.
+}
+
+// genType is the input for genfieldsTemplate.
+type genType struct {
+	Type   string
+	Fields []genField
+
+	// Embed fields, can only be used with UpdateField and UpdateFields, not for
+	// filtering or sorting.
+	Embeds []genField
+
+	// Query methods taking a field name, by parameters after the field name.
+	FilterEqualOps []string // values ...any
+	FilterValueOps []string // value any
+	SortOps        []string // Multiple fields.
+}
+
+var genfieldsTemplate = template.Must(template.New("genfields").Parse(`
+// {{.Type}}Field is the name of a field of {{.Type}}, for use with the
+// {{.Type}}Filter*, {{.Type}}Sort* and {{.Type}}Update* functions.
+type {{.Type}}Field string
+
+// Fields of {{.Type}}.
+const (
+{{- range .Fields}}
+	{{$.Type}}Field{{.GoName}} {{$.Type}}Field = {{printf "%q" .Name}}
+{{- end}}
+)
+{{if .Embeds}}
+// {{.Type}}EmbedField is the name of an embed field of {{.Type}}, for use with
+// the {{.Type}}Update* functions only, embed fields cannot be filtered or sorted on.
+type {{.Type}}EmbedField string
+
+// Embed fields of {{.Type}}.
+const (
+{{- range .Embeds}}
+	{{$.Type}}EmbedField{{.GoName}} {{$.Type}}EmbedField = {{printf "%q" .Name}}
+{{- end}}
+)
+{{end}}
+// {{.Type}}FieldNames returns fields as strings, for Query methods that take field names.
+func {{.Type}}FieldNames(fields ...{{.Type}}Field) []string {
+	l := make([]string, len(fields))
+	for i, f := range fields {
+		l[i] = string(f)
+	}
+	return l
+}
+{{range $op := .FilterEqualOps}}
+// {{$.Type}}{{$op}} calls q.{{$op}} for field.
+func {{$.Type}}{{$op}}(q *bstore.Query[{{$.Type}}], field {{$.Type}}Field, values ...any) *bstore.Query[{{$.Type}}] {
+	return q.{{$op}}(string(field), values...)
+}
+{{end}}
+{{- range $op := .FilterValueOps}}
+// {{$.Type}}{{$op}} calls q.{{$op}} for field.
+func {{$.Type}}{{$op}}(q *bstore.Query[{{$.Type}}], field {{$.Type}}Field, value any) *bstore.Query[{{$.Type}}] {
+	return q.{{$op}}(string(field), value)
+}
+{{end}}
+{{- range $op := .SortOps}}
+// {{$.Type}}{{$op}} calls q.{{$op}} for fields.
+func {{$.Type}}{{$op}}(q *bstore.Query[{{$.Type}}], fields ...{{$.Type}}Field) *bstore.Query[{{$.Type}}] {
+	return q.{{$op}}({{$.Type}}FieldNames(fields...)...)
+}
+{{end}}
+{{- if .Embeds}}
+// {{.Type}}UpdateField calls q.UpdateField for field.
+func {{.Type}}UpdateField[F {{.Type}}Field | {{.Type}}EmbedField](q *bstore.Query[{{.Type}}], field F, value any) (int, error) {
+	return q.UpdateField(string(field), value)
+}
+
+// {{.Type}}UpdateFields calls q.UpdateFields for the fields in fieldValues.
+func {{.Type}}UpdateFields[F {{.Type}}Field | {{.Type}}EmbedField](q *bstore.Query[{{.Type}}], fieldValues map[F]any) (int, error) {
+{{- else}}
+// {{.Type}}UpdateField calls q.UpdateField for field.
+func {{.Type}}UpdateField(q *bstore.Query[{{.Type}}], field {{.Type}}Field, value any) (int, error) {
+	return q.UpdateField(string(field), value)
+}
+
+// {{.Type}}UpdateFields calls q.UpdateFields for the fields in fieldValues.
+func {{.Type}}UpdateFields(q *bstore.Query[{{.Type}}], fieldValues map[{{.Type}}Field]any) (int, error) {
+{{- end}}
+	m := make(map[string]any, len(fieldValues))
+	for f, v := range fieldValues {
+		m[string(f)] = v
+	}
+	return q.UpdateFields(m)
+}
+
+// Fails to compile if fields of {{.Type}} were renamed or removed. Run "bstore genfields" again.
+func _() {
+	var v {{.Type}}
+{{- range .Fields}}
+	_ = v.{{.GoName}}
+{{- end}}
+{{- range .Embeds}}
+	_ = v.{{.GoName}}
+{{- end}}
+}
+`))
+
+func hasBstoreTag(st *ast.StructType) bool {
+	for _, f := range st.Fields.List {
+		if _, ok := fieldTag(f); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldTag returns the bstore struct tag words for a field.
+func fieldTag(f *ast.Field) ([]string, bool) {
+	if f.Tag == nil {
+		return nil, false
+	}
+	s, err := strconv.Unquote(f.Tag.Value)
+	if err != nil {
+		return nil, false
+	}
+	tag, ok := reflect.StructTag(s).Lookup("bstore")
+	if !ok {
+		return nil, false
+	}
+	return strings.Split(tag, ","), true
+}
+
+// gatherGenFields gathers the stored fields of st, like bstore does when
+// registering a type. Fields of embedded struct types defined in the same
+// package are included. Embed fields themselves are added to embeds, they can
+// be used with Query.UpdateField, but not for filtering or sorting. Types in
+// seen are being gathered, to prevent recursing into cyclic embeds. Names in
+// have have already been added.
+func gatherGenFields(structs map[string]*ast.StructType, st *ast.StructType, seen, have map[string]bool, fields, embeds *[]genField) {
+	for _, f := range st.Fields.List {
+		words, _ := fieldTag(f)
+		if slices.Contains(words, "-") {
+			continue
+		}
+		var name string
+		for _, w := range words {
+			if s, ok := strings.CutPrefix(w, "name "); ok {
+				name = s
+			}
+		}
+
+		if len(f.Names) == 0 {
+			// Embed field, named after its type.
+			t := f.Type
+			if se, ok := t.(*ast.StarExpr); ok {
+				t = se.X
+			}
+			var goName string
+			var local *ast.StructType
+			switch x := t.(type) {
+			case *ast.Ident:
+				goName = x.Name
+				local = structs[x.Name]
+			case *ast.SelectorExpr:
+				goName = x.Sel.Name
+			default:
+				continue
+			}
+			if !ast.IsExported(goName) || have[goName] {
+				continue
+			}
+			have[goName] = true
+			if name == "" {
+				name = goName
+			}
+			*embeds = append(*embeds, genField{goName, name})
+			if local != nil && !seen[goName] {
+				seen[goName] = true
+				gatherGenFields(structs, local, seen, have, fields, embeds)
+				delete(seen, goName)
+			}
+			continue
+		}
+
+		for _, ident := range f.Names {
+			goName := ident.Name
+			if !ast.IsExported(goName) || have[goName] {
+				continue
+			}
+			have[goName] = true
+			xname := name
+			if xname == "" {
+				xname = goName
+			}
+			*fields = append(*fields, genField{goName, xname})
+		}
+	}
+}
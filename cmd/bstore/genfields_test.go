@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenfields(t *testing.T) {
+	// Generate into a copy of the fixture package, within the module so it can
+	// import bstore.
+	dir, err := os.MkdirTemp("testdata", "tmp.genfields")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	copyFile := func(src, dst string) {
+		t.Helper()
+		buf, err := os.ReadFile(src)
+		if err != nil {
+			t.Fatalf("reading fixture: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, dst), buf, 0644); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+	}
+	copyFile("testdata/genfields/fixture.go", "fixture.go")
+
+	genfields([]string{dir})
+	buf, err := os.ReadFile(filepath.Join(dir, "bstorefields.go"))
+	if err != nil {
+		t.Fatalf("reading generated code: %v", err)
+	}
+	code := string(buf)
+	// Constants are aligned by gofmt.
+	words := strings.Join(strings.Fields(code), " ")
+	for _, s := range []string{`UserFieldName UserField = "Name"`, `UserFieldEmail UserField = "Mail"`, `UserFieldCreated UserField = "Created"`, "func UserFilterEqual("} {
+		if !strings.Contains(words, s) {
+			t.Fatalf("generated code does not contain %q:\n%s", s, code)
+		}
+	}
+	for _, s := range []string{"Local", "Plain", "secret", "Skip"} {
+		if strings.Contains(code, s) {
+			t.Fatalf("generated code contains %q:\n%s", s, code)
+		}
+	}
+
+	// Code using the generated functions must compile.
+	copyFile("testdata/genfields/use.go.txt", "use.go")
+	cmd := exec.Command("go", "vet", "./"+dir)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("compiling generated code: %v\n%s\n%s", err, out, code)
+	}
+
+	// Embed fields cannot be used for filtering and sorting.
+	copyFile("testdata/genfields/embed.go.txt", "embed.go")
+	cmd = exec.Command("go", "build", "./"+dir)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("compiling generated code with filter and sort on embed field succeeded")
+	}
+	if n := strings.Count(string(out), "cannot use UserEmbedFieldBase"); n != 2 {
+		t.Fatalf("compiling with filter and sort on embed field, got %d errors for embed field, expected 2:\n%s", n, out)
+	}
+}
@@ -0,0 +1,31 @@
+package fixture
+
+import (
+	"time"
+)
+
+type Base struct {
+	Created time.Time `bstore:"nonzero"`
+}
+
+type User struct {
+	ID     int64
+	Name   string `bstore:"unique"`
+	Email  string `bstore:"name Mail,index"`
+	secret string
+	Skip   int `bstore:"-"`
+	Base
+}
+
+type Plain struct {
+	ID int
+}
+
+func handler() {
+	// Types declared in function bodies are not package-level and must be skipped.
+	type Local struct {
+		ID   int
+		Name string `bstore:"unique"`
+	}
+	_ = Local{}
+}
@@ -0,0 +1,247 @@
+package bstore
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+/*
+Some field type changes need the stored records to be rewritten: between signed
+and unsigned integers, between strings and []byte, between integers and strings,
+and between booleans and integers. Register checks that every stored value
+converts without loss, and rewrites all records to the new typeVersion. Old
+typeVersions are prepared to parse such fields with convertValue.
+
+Primary key type changes, including integer widening, also rewrite the records:
+each key is converted and records are stored under the new keys. All indices of
+the type are recreated. Types referencing the type must change their reference
+fields to the new primary key type in the same Register call, their values are
+converted and their indices recreated the same way.
+*/
+
+// kindClass returns the conversion class of a kind. Changes within a class are
+// handled by regular parsing, changes between classes need a conversion.
+func kindClass(k kind) string {
+	switch k {
+	case kindInt, kindInt8, kindInt16, kindInt32, kindInt64:
+		return "int"
+	case kindUint, kindUint8, kindUint16, kindUint32, kindUint64:
+		return "uint"
+	}
+	return string(k)
+}
+
+// needsConversion returns whether values stored as ft must be converted for
+// nft.
+func needsConversion(ft, nft fieldType) bool {
+	return kindClass(ft.Kind) != kindClass(nft.Kind)
+}
+
+// convertible returns whether field values of type ft can be converted to nft by
+// rewriting records.
+func convertible(ft, nft fieldType) bool {
+	if !needsConversion(ft, nft) {
+		return false
+	}
+	oc, nc := kindClass(ft.Kind), kindClass(nft.Kind)
+	switch {
+	case oc == "int" && nc == "uint", oc == "uint" && nc == "int":
+	case oc == "string" && nc == "bytes", oc == "bytes" && nc == "string":
+	case (oc == "int" || oc == "uint") && nc == "string", oc == "string" && (nc == "int" || nc == "uint"):
+	case oc == "bool" && (nc == "int" || nc == "uint"), (oc == "int" || oc == "uint") && nc == "bool":
+	default:
+		return false
+	}
+	return true
+}
+
+// convertValue sets old value ov, as returned by fieldType.parseValue, in rv of
+// the new Go type, possibly a pointer. An error is returned if the value
+// cannot be represented exactly.
+func convertValue(ov any, rv reflect.Value) error {
+	if rv.Kind() == reflect.Ptr {
+		nrv := reflect.New(rv.Type().Elem())
+		rv.Set(nrv)
+		rv = nrv.Elem()
+	}
+
+	// Normalize integers.
+	var i int64
+	var u uint64
+	var neg, isInt bool
+	switch v := reflect.ValueOf(ov); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i = v.Int()
+		u = uint64(i)
+		neg = i < 0
+		isInt = true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u = v.Uint()
+		i = int64(u)
+		isInt = true
+	}
+
+	setInt := func() error {
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !neg && u > math.MaxInt64 || rv.OverflowInt(i) || rv.Kind() == reflect.Int && (i < math.MinInt32 || i > math.MaxInt32) {
+				return fmt.Errorf("value %v does not fit in %v", ov, rv.Type())
+			}
+			rv.SetInt(i)
+		default:
+			if neg || rv.OverflowUint(u) || rv.Kind() == reflect.Uint && u > math.MaxUint32 {
+				return fmt.Errorf("value %v does not fit in %v", ov, rv.Type())
+			}
+			rv.SetUint(u)
+		}
+		return nil
+	}
+
+	switch v := ov.(type) {
+	case bool:
+		if v {
+			i, u = 1, 1
+		}
+		return setInt()
+	case string:
+		switch rv.Kind() {
+		case reflect.Slice:
+			rv.SetBytes([]byte(v))
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			x, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || strconv.FormatInt(x, 10) != v {
+				return fmt.Errorf("string %q is not an integer", v)
+			}
+			i, u, neg = x, uint64(x), x < 0
+			return setInt()
+		default:
+			x, err := strconv.ParseUint(v, 10, 64)
+			if err != nil || strconv.FormatUint(x, 10) != v {
+				return fmt.Errorf("string %q is not an unsigned integer", v)
+			}
+			i, u = int64(x), x
+			return setInt()
+		}
+	case []byte:
+		rv.SetString(string(v))
+		return nil
+	}
+	if !isInt {
+		return fmt.Errorf("cannot convert %T", ov)
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		if neg {
+			rv.SetString(strconv.FormatInt(i, 10))
+		} else {
+			rv.SetString(strconv.FormatUint(u, 10))
+		}
+	case reflect.Bool:
+		if u > 1 {
+			return fmt.Errorf("value %v is not 0 or 1", ov)
+		}
+		rv.SetBool(u == 1)
+	default:
+		return setInt()
+	}
+	return nil
+}
+
+// rewriteRecords parses all records of st, converting field values of older
+// typeVersions, and stores them with the current typeVersion. If okey is not
+// nil, the primary key type changed from okey, and records are stored under
+// their converted keys. If values cannot be converted, ErrIncompatible is
+// returned with the primary keys of the records.
+func (tx *Tx) rewriteRecords(st storeType, rb *bolt.Bucket, okey *fieldType) error {
+	type record struct{ ok, k, v []byte }
+	var records []record
+	var badPKs []any
+	var firstErr error
+	ctxDone := tx.ctx.Done()
+	err := rb.ForEach(func(bk, bv []byte) error {
+		tx.stats.Records.Cursor++
+
+		select {
+		case <-ctxDone:
+			return tx.ctx.Err()
+		default:
+		}
+
+		rv := reflect.New(st.Type).Elem()
+		nk := bk
+		var opk any // Old primary key, for errors about records with a changed key type.
+		var err error
+		if okey == nil {
+			err = st.parseFull(rv, bk, bv)
+		} else if opk, nk, err = convertKey(*okey, rv.Field(0), bk); err == nil {
+			err = st.parse(rv, bv)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if opk == nil {
+				pkv := reflect.New(st.Current.Fields[0].structField.Type).Elem()
+				parsePK(pkv, bk) // Ignore error, value is only informational.
+				opk = pkv.Interface()
+			}
+			badPKs = append(badPKs, opk)
+			return nil
+		}
+		if badPKs != nil {
+			return nil
+		}
+		v, err := st.pack(rv)
+		if err != nil {
+			return fmt.Errorf("packing record %v: %w", rv.Field(0).Interface(), err)
+		}
+		records = append(records, record{append([]byte{}, bk...), append([]byte{}, nk...), v})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if badPKs != nil {
+		return fmt.Errorf("%w: cannot convert values of %d records with primary keys %v: %v", ErrIncompatible, len(badPKs), badPKs, firstErr)
+	}
+	if okey != nil {
+		// Remove all old keys first, old and new keys can overlap.
+		for _, r := range records {
+			tx.stats.Records.Delete++
+			if err := rb.Delete(r.ok); err != nil {
+				return fmt.Errorf("%w: removing record with old primary key: %s", ErrStore, err)
+			}
+		}
+	}
+	for _, r := range records {
+		tx.stats.Records.Put++
+		if err := rb.Put(r.k, r.v); err != nil {
+			return fmt.Errorf("%w: storing rewritten record: %s", ErrStore, err)
+		}
+	}
+	tx.bucketReseek(rb)
+	return nil
+}
+
+// convertKey parses primary key bk stored with old type okey, and sets it in
+// primary key rv of the current type. The old primary key value and the new key
+// are returned.
+func convertKey(okey fieldType, rv reflect.Value, bk []byte) (any, []byte, error) {
+	orv := reflect.New(reflect.TypeOf(okey.zeroKey())).Elem()
+	if err := parsePK(orv, bk); err != nil {
+		return nil, nil, err
+	}
+	if err := convertValue(orv.Interface(), rv); err != nil {
+		return orv.Interface(), nil, fmt.Errorf("primary key: %w", err)
+	}
+	nk, err := packPK(rv)
+	if err != nil {
+		return orv.Interface(), nil, err
+	}
+	return orv.Interface(), nk, nil
+}
@@ -3,29 +3,33 @@ package bstore
 import (
 	"fmt"
 	"reflect"
+	"slices"
 	"time"
+
+	bolt "go.etcd.io/bbolt"
 )
 
 var zerotime = time.Time{}
 
-// applyDefault replaces zero values for fields that have a Default value configured.
-func (tv *typeVersion) applyDefault(rv reflect.Value) error {
+// applyDefault replaces zero values for fields that have a Default value
+// configured. Default "now" for times is evaluated with now.
+func (tv *typeVersion) applyDefault(rv reflect.Value, now func() time.Time) error {
 	for _, f := range tv.Fields[1:] {
 		fv := rv.FieldByIndex(f.structField.Index)
-		if err := f.applyDefault(fv); err != nil {
+		if err := f.applyDefault(fv, now); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (f field) applyDefault(rv reflect.Value) error {
+func (f field) applyDefault(rv reflect.Value, now func() time.Time) error {
 	switch f.Type.Kind {
 	case kindBytes, kindBinaryMarshal, kindMap:
 		return nil
 
 	case kindSlice, kindStruct, kindArray:
-		return f.Type.applyDefault(rv)
+		return f.Type.applyDefault(rv, now)
 
 	case kindBool, kindInt, kindInt8, kindInt16, kindInt32, kindInt64, kindUint, kindUint8, kindUint16, kindUint32, kindUint64, kindFloat32, kindFloat64, kindString, kindTime:
 		if !f.defaultValue.IsValid() || !rv.IsZero() {
@@ -34,11 +38,11 @@ func (f field) applyDefault(rv reflect.Value) error {
 		fv := f.defaultValue
 		// Time is special. "now" is encoded as the zero value of time.Time.
 		if f.Type.Kind == kindTime && fv.Interface() == zerotime {
-			now := time.Now().Round(0)
+			tm := now()
 			if f.Type.Ptr {
-				fv = reflect.ValueOf(&now)
+				fv = reflect.ValueOf(&tm)
 			} else {
-				fv = reflect.ValueOf(now)
+				fv = reflect.ValueOf(tm)
 			}
 		} else if f.Type.Ptr {
 			fv = reflect.New(f.structField.Type.Elem())
@@ -52,9 +56,69 @@ func (f field) applyDefault(rv reflect.Value) error {
 	}
 }
 
+// backfillFields returns the fields of ntv with struct tag "default <value>
+// backfill" that are not in otv.
+func backfillFields(otv, ntv *typeVersion) []field {
+	var l []field
+	for _, f := range ntv.Fields[1:] {
+		if !f.backfill {
+			continue
+		}
+		if !slices.ContainsFunc(otv.Fields, func(of field) bool { return of.Name == f.Name || of.Name == f.OldName }) {
+			l = append(l, f)
+		}
+	}
+	return l
+}
+
+// backfillDefaults sets the default value of fields in all records of st that
+// have a zero value for them, i.e. all records stored before the fields were
+// added.
+func (tx *Tx) backfillDefaults(st storeType, rb *bolt.Bucket, fields []field) error {
+	type record struct{ k, v []byte }
+	var records []record
+	ctxDone := tx.ctx.Done()
+	err := rb.ForEach(func(bk, bv []byte) error {
+		tx.stats.Records.Cursor++
+
+		select {
+		case <-ctxDone:
+			return tx.ctx.Err()
+		default:
+		}
+
+		rv, err := st.parseNew(bk, bv)
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			if err := f.applyDefault(rv.FieldByIndex(f.structField.Index), tx.db.now); err != nil {
+				return err
+			}
+		}
+		v, err := st.pack(rv)
+		if err != nil {
+			return fmt.Errorf("packing record %v: %w", rv.Field(0).Interface(), err)
+		}
+		records = append(records, record{append([]byte{}, bk...), v})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		tx.stats.Records.Put++
+		if err := rb.Put(r.k, r.v); err != nil {
+			return fmt.Errorf("%w: storing record with default values: %s", ErrStore, err)
+		}
+	}
+	tx.bucketReseek(rb)
+	return nil
+}
+
 // only for recursing. we do not support recursing into maps because it would
 // involve more work making values settable. and how sensible is it anyway?
-func (ft fieldType) applyDefault(rv reflect.Value) error {
+func (ft fieldType) applyDefault(rv reflect.Value, now func() time.Time) error {
 	if ft.Ptr && rv.IsZero() {
 		return nil
 	} else if ft.Ptr {
@@ -64,24 +128,65 @@ func (ft fieldType) applyDefault(rv reflect.Value) error {
 	case kindSlice:
 		n := rv.Len()
 		for i := 0; i < n; i++ {
-			if err := ft.ListElem.applyDefault(rv.Index(i)); err != nil {
+			if err := ft.ListElem.applyDefault(rv.Index(i), now); err != nil {
 				return err
 			}
 		}
 	case kindArray:
 		n := ft.ArrayLength
 		for i := 0; i < n; i++ {
-			if err := ft.ListElem.applyDefault(rv.Index(i)); err != nil {
+			if err := ft.ListElem.applyDefault(rv.Index(i), now); err != nil {
 				return err
 			}
 		}
 	case kindStruct:
 		for _, nf := range ft.structFields {
 			nfv := rv.FieldByIndex(nf.structField.Index)
-			if err := nf.applyDefault(nfv); err != nil {
+			if err := nf.applyDefault(nfv, now); err != nil {
 				return err
 			}
 		}
 	}
 	return nil
 }
+
+// setAutoTimes sets the fields with struct tags "autocreate" and "autoupdate"
+// to the current time. For inserts, rov is the zero reflect.Value, and zero
+// fields are set. For updates, autoupdate fields are always set.
+func (tv *typeVersion) setAutoTimes(rv, rov reflect.Value, now func() time.Time) {
+	var tm time.Time
+	for _, f := range tv.Fields[1:] {
+		if f.Auto == "" {
+			continue
+		}
+		fv := rv.FieldByIndex(f.structField.Index)
+		if rov.IsValid() && f.Auto == "create" {
+			continue
+		} else if !rov.IsValid() && !fv.IsZero() {
+			continue
+		}
+		if tm.IsZero() {
+			tm = now()
+		}
+		if f.Type.Ptr {
+			v := tm
+			fv.Set(reflect.ValueOf(&v))
+		} else {
+			fv.Set(reflect.ValueOf(tm))
+		}
+	}
+}
+
+// keepCreateTimes copies fields with struct tag "autocreate" from rov to rv if
+// they are zero in rv.
+func (tv *typeVersion) keepCreateTimes(rv, rov reflect.Value) {
+	for _, f := range tv.Fields[1:] {
+		if f.Auto != "create" {
+			continue
+		}
+		fv := rv.FieldByIndex(f.structField.Index)
+		if fv.IsZero() {
+			fv.Set(rov.FieldByIndex(f.structField.Index))
+		}
+	}
+}
@@ -60,8 +60,15 @@ some have multiple space-separated words:
   - "noauto", only valid for integer types, and only for the primary key. By
     default, an integer-typed primary key will automatically get a next value
     assigned on insert when it is 0. With noauto inserting a 0 value results in an
-    error. For primary keys of other types inserting the zero value always results
-    in an error.
+    error. For primary keys of other types inserting the zero value results in an
+    error, unless "auto" is set.
+  - "auto <generator>", only for the primary key of type string, []byte or
+    [16]byte. Inserting a zero value generates a new primary key. Generator
+    "uuid" generates random UUIDs (version 4), "uuidv7" time-ordered UUIDs
+    (version 7), and "ulid" time-ordered ULIDs. Time-ordered IDs sort by their
+    millisecond creation time (see Options.Now). Strings are formatted as
+    lower-case UUIDs with dashes, or 26-character ULIDs. Useful for records that
+    are synchronized between systems.
   - "index" or "index <field1>+<field2>+<...> [<name>]", adds an index. In the
     first form, the index is on the field on which the tag is specified, and the
     index name is the same as the field name. In the second form multiple fields can
@@ -83,16 +90,81 @@ some have multiple space-separated words:
     for efficiently checking that removed records in the referenced type are not in
     use. If the field has the zero value, the reference is not checked. If you
     require a valid reference, add "nonzero".
+  - "ref <type> cascade" or "ref <type> setzero", like "ref <type>", but
+    deleting a referenced record does not fail with ErrReference. Instead,
+    with "cascade" the referencing records are deleted as well (recursively,
+    also taking actions for records referencing those), and with "setzero" the
+    field of the referencing records is set to the zero value. "setzero" cannot
+    be combined with "nonzero".
   - "default <value>", replaces a zero value with the specified value on record
     insert. Special value "now" is recognized for time.Time as the current time.
     Times are parsed as time.RFC3339 otherwise. Supported types: bool
     ("true"/"false"), integers, floats, strings. Value is not quoted and no escaping
     of special characters, like the comma that separates struct tag words, is
     possible.  Defaults are also replaced on fields in nested structs, slices
-    and arrays, but not in maps.
+    and arrays, but not in maps. With "default <value> backfill", on a field
+    of the top-level struct, the default value is also written into existing
+    records when the field is added to the type. This allows adding a field
+    with both a default value and a nonzero constraint in one schema change.
+  - "autocreate", for time.Time fields (possibly pointers) of the top-level
+    struct. Sets the field to the current time on insert, if it is zero. On
+    update, a zero value is replaced with the stored value.
+  - "autoupdate", like "autocreate", but the field is also set to the current
+    time on each update that changes the record, including through
+    Query.UpdateField and Query.UpdateFields. The current time can be set through
+    Options.Now.
+  - "version", for an integer field of the top-level struct, for optimistic
+    concurrency control. On insert, a zero value is set to 1. On update, the
+    value must match the stored record, otherwise ErrVersion is returned. The
+    version is incremented for each update that changes the record. Read a
+    record, modify it, and update it with the version that was read, e.g. in a
+    later transaction, to detect concurrent modifications.
+  - "seq", for an integer field of the top-level struct, assigned the next
+    value from a per-field sequence on insert if it is zero. Explicitly set
+    values are kept, and later values continue after them. A sequence for a
+    field added to a type with existing records starts after their highest
+    value.
+  - "modseq", like "seq", but assigned the next value on insert and on each
+    update that changes the record, useful for synchronizing changes.
+  - "enum <value1> <value2> <...>", only allows the space-separated values.
+    For strings and integers.
+  - "min <value>" and "max <value>", only allow values within the inclusive
+    bound. For integers and floats.
+  - "maxlen <n>", limits the length of strings (in characters), []byte (in
+    bytes), and slices and maps (in elements).
+  - "pattern <regexp>", only allows strings matching the regular expression.
+    Use ^ and $ to match the full string. The regular expression cannot contain
+    a comma.
   - "typename <name>", override name of the type. The name of the Go type is
     used by default. Can only be present on the first field (primary key).
     Useful for doing schema updates.
+  - "oldname <fieldname>", for a field of the top-level struct that was renamed.
+    The stored values of the field with the old name are kept, and read as values
+    of the renamed field. Records are not rewritten. The tag can remain in place
+    after the rename has been registered.
+
+Values violating "enum", "min", "max", "maxlen" or "pattern" are rejected with
+ErrCheck on insert and update. Zero values are checked as well, nil pointers are
+not.
+
+Named counters, independent of types, can be incremented with Tx.NextSeq and
+DB.NextSeq, e.g. for generating identifiers shared between types.
+
+A primary key can be composite: a struct type whose fields are all exported and
+of type bool, integer or string, without struct tags. The key is stored as the
+concatenation of its fields, like index keys, and records are ordered by the
+fields of the key. Composite keys can be used with Get, FilterID, FilterIDs and
+in "ref" fields of the same struct type. Composite keys are never generated, the
+zero value cannot be inserted. Their fields cannot be changed once stored.
+
+Types can also be defined at runtime, without a Go struct type, with
+NewDynamicType: a list of field names, kinds and struct tags. A DynamicType is
+registered like a Go type. Records of registered types, dynamic or not, can be
+inserted, updated, fetched and deleted as maps from field name to value with
+Tx.InsertRecord, Tx.UpdateRecord, Tx.GetRecord and Tx.DeleteRecord.
+Tx.QueryRecords selects records of any stored type, also when not registered,
+with filters on field values, sorting and a limit, returning maps. It uses the
+primary key or the indices of the stored type definition when possible.
 
 # Schema updates
 
@@ -102,7 +174,9 @@ For each type, a type definition is stored in the database. If a type has an
 updated definition since the previous database open, a new type definition is
 added to the database automatically and any required modifications are made and
 checked: Indexes (re)created, fields added/removed, new
-nonzero/unique/reference constraints validated.
+nonzero/unique/reference constraints validated. Renaming a Go field removes the
+old field and adds a new field, unless the rename is marked with struct tag
+"oldname".
 
 As a special case, you can change field types between pointer and non-pointer
 types. With one exception: changing from pointer to non-pointer where the type
@@ -118,8 +192,9 @@ Some schema conversions are not allowed. In some cases due to architectural
 limitations. In some cases because the constraint checks haven't been
 implemented yet, or the parsing code does not yet know how to parse the old
 on-disk values into the updated Go types. If you need a conversion that is not
-supported, you will need to write a manual conversion, and you would have to
-keep track whether the update has been executed.
+supported, you will need to write a manual conversion. DB.Migrate, or
+Options.Migrations, runs named migrations and keeps track of which have been
+executed.
 
 Changes that are allowed:
 
@@ -132,17 +207,27 @@ Changes that are allowed:
   - Adding/removing a reference. When a reference is added, the current records
     are verified to be valid references.
   - Add/remove a nonzero constraint. Existing records are verified.
+  - Add/remove/modify value checks ("enum", "min", "max", "maxlen", "pattern").
+    Existing records are verified.
+  - For fields of the top-level struct: between signed and unsigned integer
+    types, between string and []byte, between integers and strings, and between
+    booleans and integers. All records are rewritten to the new type during
+    Register. Each value must convert exactly: integers must fit in the new type,
+    strings must be integers in canonical base 10 form, integers converted to
+    booleans must be 0 or 1. Zero values remain zero values. If a value does not
+    convert, Register fails with ErrIncompatible, mentioning the primary keys of
+    the records, and the database is not changed.
+  - Type of a non-composite primary key, to a wider integer type or with the
+    conversions above. All records are stored under their converted keys and
+    all indices of the type are recreated, in the Register transaction. Types
+    referencing the type must change their "ref" fields to the new primary key
+    type in the same Register call. The autoincrement sequence is kept.
 
 Conversions that are not currently allowed, but may be in the future:
 
-  - Signedness of integer types. With a one-time check that old values fit in the new
-    type, this could be allowed in the future.
-  - Conversions between basic types: strings, []byte, integers, floats, boolean.
-    Checks would have to be added for some of these conversions. For example,
-    from string to integer: the on-disk string values would have to be valid
-    integers.
-  - Types of primary keys cannot be changed, also not from one integer type to a
-    wider integer type of same signedness.
+  - Conversions involving floats, and conversions of fields in nested structs,
+    slices or maps. Narrowing integer types of the same signedness.
+  - Changes to composite primary keys.
 
 # Bolt and storage
 
@@ -167,7 +252,11 @@ exists with two subbuckets:
   - "types", with type descriptions of the stored records. Each time the database
     file is opened with a modified Go type (add/removed/modified
     field/type/bstore struct tag), a new type description is automatically added,
-    identified by sequence number.
+    identified by sequence number. Old type descriptions are kept as long as
+    records may use them. DB.UpgradeRecords rewrites all records to the
+    current type description and removes the old type descriptions.
+    Tx.Schemas returns the stored type descriptions, with the number of records
+    per description.
   - "records", containing all data, with the type's primary key as Bolt key,
     and the encoded remaining fields as value. The encoding starts with a
     reference to a type description.
@@ -0,0 +1,155 @@
+package bstore
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+)
+
+// SchemaDiff describes the changes Register would make for a type, as returned
+// by DB.RegisterDryRun.
+type SchemaDiff struct {
+	Type    string // Name of the type.
+	New     bool   // Whether the type is not yet in the database.
+	Changed bool   // Whether a new type version would be added.
+	Version uint32 // Type version after registering.
+
+	FieldsAdded   []string          `json:",omitempty"`
+	FieldsRemoved []string          `json:",omitempty"`
+	FieldsRenamed map[string]string `json:",omitempty"` // New name to old name, from struct tag "oldname".
+	FieldsChanged []string          `json:",omitempty"` // Fields with a changed type or struct tags.
+
+	IndicesCreated []string `json:",omitempty"` // New or modified indices, filled from existing records.
+	IndicesDropped []string `json:",omitempty"` // Removed or modified indices.
+
+	// Work on existing records done while registering, each can fail the
+	// registration: "nonzero <field>", "ref <field> <type>", "check <field>",
+	// "convert <field>", "primary key", "backfill <field>", "unique <index>",
+	// "validate".
+	Checks []string `json:",omitempty"`
+}
+
+// errDryRun rolls back the transaction of a dry run registration.
+var errDryRun = errors.New("dry run")
+
+// RegisterDryRun runs the same checks on the database as Register or Open would
+// for typeValues, in a transaction that is rolled back, and returns the changes
+// for each type. The types are registered as if the database were opened
+// without types, types registered with db are not used or changed.
+//
+// If registration would fail, e.g. because existing records do not satisfy a
+// new constraint, the error is returned, along with the changes found until
+// then.
+func (db *DB) RegisterDryRun(ctx context.Context, typeValues ...any) ([]SchemaDiff, error) {
+	ndb := &DB{
+		bdb:              db.bdb,
+		types:            map[reflect.Type]storeType{},
+		typeNames:        map[string]storeType{},
+		registerValidate: db.registerValidate,
+		clock:            db.clock,
+		hooks:            db.hooks,
+	}
+	diffs := []SchemaDiff{}
+	err := ndb.register(ctx, slog.New(discardHandler{}), &diffs, typeValues...)
+	if err == errDryRun {
+		err = nil
+	}
+	db.statsMutex.Lock()
+	db.stats.add(ndb.Stats())
+	db.statsMutex.Unlock()
+	return diffs, err
+}
+
+// schemaDiff returns the changes from otv, which is nil for a new type, to ntv.
+func schemaDiff(otv, ntv *typeVersion, validate bool) SchemaDiff {
+	d := SchemaDiff{Type: ntv.name, New: otv == nil, Changed: true, Version: ntv.Version}
+	if otv == nil {
+		for _, f := range ntv.Fields {
+			d.FieldsAdded = append(d.FieldsAdded, f.Name)
+		}
+		for name := range ntv.Indices {
+			d.IndicesCreated = append(d.IndicesCreated, name)
+		}
+		slices.Sort(d.IndicesCreated)
+		return d
+	}
+
+	if otv.Fields[0].Type.Kind != ntv.Fields[0].Type.Kind {
+		d.Checks = append(d.Checks, "primary key")
+	}
+
+	matched := map[string]bool{}
+	for _, f := range ntv.Fields {
+		i := slices.IndexFunc(otv.Fields, func(of field) bool { return of.Name == f.Name || of.Name == f.OldName })
+		if i < 0 {
+			d.FieldsAdded = append(d.FieldsAdded, f.Name)
+			if f.Nonzero {
+				d.Checks = append(d.Checks, "nonzero "+f.Name)
+			}
+			for _, ref := range f.References {
+				d.Checks = append(d.Checks, "ref "+f.Name+" "+ref)
+			}
+			if f.backfill {
+				d.Checks = append(d.Checks, "backfill "+f.Name)
+			}
+			continue
+		}
+		of := otv.Fields[i]
+		matched[of.Name] = true
+		if of.Name != f.Name {
+			if d.FieldsRenamed == nil {
+				d.FieldsRenamed = map[string]string{}
+			}
+			d.FieldsRenamed[f.Name] = of.Name
+		}
+		xof := of
+		xof.Name = f.Name
+		if xof.typeEqual(f) {
+			continue
+		}
+		d.FieldsChanged = append(d.FieldsChanged, f.Name)
+		if f.Nonzero && !of.Nonzero {
+			d.Checks = append(d.Checks, "nonzero "+f.Name)
+		}
+		for _, ref := range f.References {
+			if !slices.Contains(of.References, ref) {
+				d.Checks = append(d.Checks, "ref "+f.Name+" "+ref)
+			}
+		}
+		if f.Check != nil && !of.Check.equal(f.Check) {
+			d.Checks = append(d.Checks, "check "+f.Name)
+		}
+		if convertible(of.Type, f.Type) {
+			d.Checks = append(d.Checks, "convert "+f.Name)
+		}
+	}
+	for _, of := range otv.Fields {
+		if !matched[of.Name] {
+			d.FieldsRemoved = append(d.FieldsRemoved, of.Name)
+		}
+	}
+
+	for name, idx := range ntv.Indices {
+		if oidx, ok := otv.Indices[name]; !ok || !oidx.typeEqual(idx) {
+			d.IndicesCreated = append(d.IndicesCreated, name)
+		}
+	}
+	for name, oidx := range otv.Indices {
+		if idx, ok := ntv.Indices[name]; !ok || !oidx.typeEqual(idx) {
+			d.IndicesDropped = append(d.IndicesDropped, name)
+		}
+	}
+	slices.Sort(d.IndicesCreated)
+	slices.Sort(d.IndicesDropped)
+	for _, name := range d.IndicesCreated {
+		if ntv.Indices[name].Unique {
+			d.Checks = append(d.Checks, "unique "+name)
+		}
+	}
+	if validate {
+		d.Checks = append(d.Checks, "validate")
+	}
+	return d
+}
@@ -0,0 +1,392 @@
+package bstore
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DynamicField describes a field of a type defined at runtime with
+// NewDynamicType.
+type DynamicField struct {
+	// Name of the field, must be an exported Go identifier.
+	Name string
+
+	// Kind of the field: "bool", "int", "int8", "int16", "int32", "int64",
+	// "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64",
+	// "string", "bytes" or "time". Kinds can be prefixed with "*" for pointers,
+	// "[]" for slices and "map[string]" for maps with string keys, e.g.
+	// "[]string".
+	Kind string
+
+	// Contents of the bstore struct tag, e.g. "unique" or "ref User,nonzero".
+	// Optional.
+	Tag string
+}
+
+// DynamicType is a type defined at runtime instead of with a Go struct type. It
+// can be passed to Open and Register like a struct value. Its records are
+// inserted, updated and read as maps with Tx.InsertRecord, Tx.UpdateRecord and
+// Tx.GetRecord, which also work for types with a Go struct type.
+type DynamicType struct {
+	Name   string
+	Fields []DynamicField
+
+	rt reflect.Type // Struct type with the fields, and a "typename" tag.
+}
+
+var dynamicKinds = map[string]reflect.Type{
+	"bool":    reflect.TypeFor[bool](),
+	"int":     reflect.TypeFor[int](),
+	"int8":    reflect.TypeFor[int8](),
+	"int16":   reflect.TypeFor[int16](),
+	"int32":   reflect.TypeFor[int32](),
+	"int64":   reflect.TypeFor[int64](),
+	"uint":    reflect.TypeFor[uint](),
+	"uint8":   reflect.TypeFor[uint8](),
+	"uint16":  reflect.TypeFor[uint16](),
+	"uint32":  reflect.TypeFor[uint32](),
+	"uint64":  reflect.TypeFor[uint64](),
+	"float32": reflect.TypeFor[float32](),
+	"float64": reflect.TypeFor[float64](),
+	"string":  reflect.TypeFor[string](),
+	"bytes":   reflect.TypeFor[[]byte](),
+	"time":    reflect.TypeFor[time.Time](),
+}
+
+// NewDynamicType returns a type named name with fields, for registering and
+// storing records without a Go struct type. The first field is the primary key.
+// The same field kinds and struct tags as for Go struct types are allowed, and
+// schema changes are handled the same way.
+func NewDynamicType(name string, fields []DynamicField) (*DynamicType, error) {
+	if name == "" || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("%w: invalid type name %q", ErrParam, name)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%w: type must have at least one field", ErrType)
+	}
+	var sfl []reflect.StructField
+	seen := map[string]bool{}
+	for i, f := range fields {
+		if !token.IsIdentifier(f.Name) || !token.IsExported(f.Name) {
+			return nil, fmt.Errorf("%w: field name %q must be an exported Go identifier", ErrParam, f.Name)
+		}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("%w: duplicate field %q", ErrParam, f.Name)
+		}
+		seen[f.Name] = true
+		t, err := dynamicKind(f.Kind)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", f.Name, err)
+		}
+		tag := f.Tag
+		if i == 0 {
+			tags, err := newStoreTags(tag, true)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", f.Name, err)
+			}
+			if tn, err := tags.Get("typename"); err != nil {
+				return nil, fmt.Errorf("field %q: %w", f.Name, err)
+			} else if tn != "" {
+				return nil, fmt.Errorf("%w: dynamic type cannot have typename tag", ErrParam)
+			}
+			tag = strings.TrimSuffix("typename "+name+","+tag, ",")
+		}
+		sf := reflect.StructField{Name: f.Name, Type: t}
+		if tag != "" {
+			sf.Tag = reflect.StructTag("bstore:" + strconv.Quote(tag))
+		}
+		sfl = append(sfl, sf)
+	}
+	dt := &DynamicType{name, fields, reflect.StructOf(sfl)}
+	if _, err := gatherTypeVersion(dt.rt); err != nil {
+		return nil, err
+	}
+	return dt, nil
+}
+
+// dynamicKind returns the Go type for a DynamicField kind.
+func dynamicKind(s string) (reflect.Type, error) {
+	if t, ok := dynamicKinds[s]; ok {
+		return t, nil
+	}
+	if rest, ok := strings.CutPrefix(s, "*"); ok {
+		t, err := dynamicKind(rest)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.PointerTo(t), nil
+	} else if rest, ok := strings.CutPrefix(s, "[]"); ok {
+		t, err := dynamicKind(rest)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(t), nil
+	} else if rest, ok := strings.CutPrefix(s, "map[string]"); ok {
+		t, err := dynamicKind(rest)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(reflect.TypeFor[string](), t), nil
+	}
+	return nil, fmt.Errorf("%w: unknown kind %q", ErrType, s)
+}
+
+// recordStoreType returns the registered type by name, for records as maps.
+func (tx *Tx) recordStoreType(typeName string) (storeType, error) {
+	st, ok := tx.db.typeNames[typeName]
+	if !ok {
+		return storeType{}, fmt.Errorf("%w: type %q not registered", ErrType, typeName)
+	}
+	return st, nil
+}
+
+// setRecordValue sets v in rv. The value must be assignable to rv, or be a
+// number that can be represented exactly in the number type of rv, or a string
+// in RFC3339 format for a time.Time, or a slice or map with such values.
+func setRecordValue(rv reflect.Value, v any) error {
+	if v == nil {
+		rv.SetZero()
+		return nil
+	}
+	vv := reflect.ValueOf(v)
+	if vv.Type().AssignableTo(rv.Type()) {
+		rv.Set(vv)
+		return nil
+	}
+	switch {
+	case rv.Kind() == reflect.Ptr:
+		nrv := reflect.New(rv.Type().Elem())
+		if err := setRecordValue(nrv.Elem(), v); err != nil {
+			return err
+		}
+		rv.Set(nrv)
+		return nil
+	case vv.Kind() == reflect.Ptr:
+		if vv.IsNil() {
+			rv.SetZero()
+			return nil
+		}
+		return setRecordValue(rv, vv.Elem().Interface())
+	case isNumber(rv.Kind()) && isNumber(vv.Kind()):
+		nv := vv.Convert(rv.Type())
+		if nv.Convert(vv.Type()).Interface() != v {
+			return fmt.Errorf("%w: value %v does not fit in %v", ErrParam, v, rv.Type())
+		}
+		rv.Set(nv)
+		return nil
+	case rv.Type() == reflect.TypeFor[time.Time]() && vv.Kind() == reflect.String:
+		tm, err := time.Parse(time.RFC3339Nano, vv.String())
+		if err != nil {
+			return fmt.Errorf("%w: parsing time: %v", ErrParam, err)
+		}
+		rv.Set(reflect.ValueOf(tm))
+		return nil
+	case rv.Kind() == reflect.Slice && vv.Kind() == reflect.Slice:
+		n := vv.Len()
+		nrv := reflect.MakeSlice(rv.Type(), n, n)
+		for i := range n {
+			if err := setRecordValue(nrv.Index(i), vv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		rv.Set(nrv)
+		return nil
+	case rv.Kind() == reflect.Map && vv.Kind() == reflect.Map && rv.Type().Key() == vv.Type().Key():
+		nrv := reflect.MakeMapWithSize(rv.Type(), vv.Len())
+		iter := vv.MapRange()
+		for iter.Next() {
+			ev := reflect.New(rv.Type().Elem()).Elem()
+			if err := setRecordValue(ev, iter.Value().Interface()); err != nil {
+				return err
+			}
+			nrv.SetMapIndex(iter.Key(), ev)
+		}
+		rv.Set(nrv)
+		return nil
+	}
+	return fmt.Errorf("%w: cannot use %T as %v", ErrParam, v, rv.Type())
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// setRecord sets the fields in record, by their stored name, in struct rv.
+func setRecord(tv *typeVersion, rv reflect.Value, record map[string]any) error {
+	for name, v := range record {
+		i := slices.IndexFunc(tv.Fields, func(f field) bool { return f.Name == name })
+		if i < 0 {
+			return fmt.Errorf("%w: unknown field %q", ErrParam, name)
+		}
+		if err := setRecordValue(rv.FieldByIndex(tv.Fields[i].structField.Index), v); err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// recordMap returns the stored fields of struct rv as map.
+func recordMap(tv *typeVersion, rv reflect.Value) map[string]any {
+	m := map[string]any{}
+	for _, f := range tv.Fields {
+		m[f.Name] = rv.FieldByIndex(f.structField.Index).Interface()
+	}
+	return m
+}
+
+// recordPtr returns a pointer to a new value of st with the primary key set
+// from key.
+func recordPtr(st storeType, key any) (reflect.Value, error) {
+	prv := reflect.New(st.Type)
+	if err := setRecordValue(prv.Elem().Field(0), key); err != nil {
+		return reflect.Value{}, fmt.Errorf("primary key: %w", err)
+	}
+	return prv, nil
+}
+
+// InsertRecord inserts record, with values by field name, as new record of
+// registered type typeName, e.g. a DynamicType. Missing fields are zero
+// values. Values must have the type of the field, or be numbers that fit, e.g.
+// float64 values from JSON. After inserting, record is updated with all fields,
+// including the assigned primary key, default values and sequences. Otherwise
+// the same as Insert.
+func (tx *Tx) InsertRecord(typeName string, record map[string]any) error {
+	if err := tx.error(); err != nil {
+		return err
+	}
+	st, err := tx.recordStoreType(typeName)
+	if err != nil {
+		return err
+	}
+	prv := reflect.New(st.Type)
+	if err := setRecord(st.Current, prv.Elem(), record); err != nil {
+		return err
+	}
+	if err := tx.Insert(prv.Interface()); err != nil {
+		return err
+	}
+	for k, v := range recordMap(st.Current, prv.Elem()) {
+		record[k] = v
+	}
+	return nil
+}
+
+// UpdateRecord updates the fields present in record, which must include the
+// primary key, of the existing record of registered type typeName. Record is
+// updated with all fields of the stored record. Otherwise the same as Update.
+func (tx *Tx) UpdateRecord(typeName string, record map[string]any) error {
+	if err := tx.error(); err != nil {
+		return err
+	}
+	st, err := tx.recordStoreType(typeName)
+	if err != nil {
+		return err
+	}
+	key, ok := record[st.Current.Fields[0].Name]
+	if !ok {
+		return fmt.Errorf("%w: missing primary key field %q", ErrParam, st.Current.Fields[0].Name)
+	}
+	prv, err := recordPtr(st, key)
+	if err != nil {
+		return err
+	}
+	if err := tx.Get(prv.Interface()); err != nil {
+		return err
+	}
+	if err := setRecord(st.Current, prv.Elem(), record); err != nil {
+		return err
+	}
+	if err := tx.Update(prv.Interface()); err != nil {
+		return err
+	}
+	for k, v := range recordMap(st.Current, prv.Elem()) {
+		record[k] = v
+	}
+	return nil
+}
+
+// GetRecord returns the record of registered type typeName with primary key
+// key, as map with values by field name. Unlike Record, the values have the
+// types of the registered type.
+//
+// ErrAbsent is returned if the record does not exist.
+func (tx *Tx) GetRecord(typeName string, key any) (map[string]any, error) {
+	if err := tx.error(); err != nil {
+		return nil, err
+	}
+	st, err := tx.recordStoreType(typeName)
+	if err != nil {
+		return nil, err
+	}
+	prv, err := recordPtr(st, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Get(prv.Interface()); err != nil {
+		return nil, err
+	}
+	return recordMap(st.Current, prv.Elem()), nil
+}
+
+// DeleteRecord deletes the record of registered type typeName with primary key
+// key. Otherwise the same as Delete.
+func (tx *Tx) DeleteRecord(typeName string, key any) error {
+	if err := tx.error(); err != nil {
+		return err
+	}
+	st, err := tx.recordStoreType(typeName)
+	if err != nil {
+		return err
+	}
+	prv, err := recordPtr(st, key)
+	if err != nil {
+		return err
+	}
+	return tx.Delete(prv.Interface())
+}
+
+// InsertRecord calls InsertRecord on a new writable Tx.
+func (db *DB) InsertRecord(ctx context.Context, typeName string, record map[string]any) error {
+	return db.Write(ctx, func(tx *Tx) error {
+		return tx.InsertRecord(typeName, record)
+	})
+}
+
+// UpdateRecord calls UpdateRecord on a new writable Tx.
+func (db *DB) UpdateRecord(ctx context.Context, typeName string, record map[string]any) error {
+	return db.Write(ctx, func(tx *Tx) error {
+		return tx.UpdateRecord(typeName, record)
+	})
+}
+
+// GetRecord calls GetRecord on a new read-only Tx.
+func (db *DB) GetRecord(ctx context.Context, typeName string, key any) (record map[string]any, rerr error) {
+	rerr = db.Read(ctx, func(tx *Tx) error {
+		var err error
+		record, err = tx.GetRecord(typeName, key)
+		return err
+	})
+	if rerr != nil {
+		return nil, rerr
+	}
+	return record, nil
+}
+
+// DeleteRecord calls DeleteRecord on a new writable Tx.
+func (db *DB) DeleteRecord(ctx context.Context, typeName string, key any) error {
+	return db.Write(ctx, func(tx *Tx) error {
+		return tx.DeleteRecord(typeName, key)
+	})
+}
@@ -2,6 +2,7 @@ package bstore
 
 import (
 	"bytes"
+	"cmp"
 	"fmt"
 	"reflect"
 	"sort"
@@ -594,6 +595,12 @@ func (e *exec[T]) checkFilter(p *pair[T]) (rok bool, rerr error) {
 	return true, nil
 }
 
+// orderable returns whether field ff can be compared for filterCompare and
+// sorting. Composite primary keys are orderable, other structs are not.
+func (q *Query[T]) orderable(ff field) bool {
+	return comparable(ff.Type) || ff.Type.Kind == kindStruct && ff.Name == q.st.Current.Fields[0].Name
+}
+
 // if type can be compared for filterCompare, eg for greater/less comparison.
 func comparable(ft fieldType) bool {
 	if ft.Ptr {
@@ -602,6 +609,9 @@ func comparable(ft fieldType) bool {
 	switch ft.Kind {
 	case kindBytes, kindString, kindBool, kindInt8, kindInt16, kindInt32, kindInt64, kindInt, kindUint8, kindUint16, kindUint32, kindUint64, kindUint, kindFloat32, kindFloat64, kindTime:
 		return true
+	case kindArray:
+		// Byte arrays, e.g. generated primary keys.
+		return ft.ListElem.Kind == kindUint8 && !ft.ListElem.Ptr
 	default:
 		return false
 	}
@@ -612,6 +622,14 @@ func compare(k kind, a, b reflect.Value) int {
 	case kindBytes:
 		return bytes.Compare(a.Bytes(), b.Bytes())
 
+	case kindArray:
+		for i := range a.Len() {
+			if r := cmp.Compare(a.Index(i).Uint(), b.Index(i).Uint()); r != 0 {
+				return r
+			}
+		}
+		return 0
+
 	case kindString:
 		sa := a.String()
 		sb := b.String()
@@ -622,6 +640,12 @@ func compare(k kind, a, b reflect.Value) int {
 		}
 		return 0
 
+	case kindStruct:
+		// Composite keys sort as their packed form.
+		pa, _ := packKeyStruct(a)
+		pb, _ := packKeyStruct(b)
+		return bytes.Compare(pa, pb)
+
 	case kindBool:
 		ba := a.Bool()
 		bb := b.Bool()
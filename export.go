@@ -22,6 +22,9 @@ func (tx *Tx) Types() ([]string, error) {
 	err := tx.btx.ForEach(func(bname []byte, b *bolt.Bucket) error {
 		// note: we do not track stats for types operations.
 
+		if string(bname) == metaBucket {
+			return nil
+		}
 		types = append(types, string(bname))
 		return nil
 	})
@@ -151,6 +154,8 @@ func (tx *Tx) Record(typeName, key string, fields *[]string) (map[string]any, er
 		kv = key
 	case kindBytes:
 		kv = []byte(key) // todo: or decode from base64?
+	case kindArray, kindStruct:
+		return nil, fmt.Errorf("%w: cannot parse primary key of kind %v from string, use Records", ErrParam, tv.Fields[0].Type.Kind)
 	default:
 		return nil, fmt.Errorf("internal error: unknown primary key kind %v", tv.Fields[0].Type.Kind)
 	}
@@ -449,6 +454,19 @@ func (ft fieldType) zeroKey() any {
 	if k == kindSlice {
 		k = ft.ListElem.Kind
 	}
+	switch k {
+	case kindArray:
+		return reflect.Zero(reflect.ArrayOf(ft.ArrayLength, reflect.TypeFor[byte]())).Interface()
+	case kindStruct:
+		// Composite key, we make a struct type with the same fields. Their names are
+		// the Go field names, composite keys cannot have a "name" tag.
+		var fields []reflect.StructField
+		for _, f := range ft.structFields {
+			t := reflect.TypeOf(f.Type.zeroKey())
+			fields = append(fields, reflect.StructField{Name: f.Name, Type: t})
+		}
+		return reflect.Zero(reflect.StructOf(fields)).Interface()
+	}
 	v, ok := zeroKeys[k]
 	if !ok {
 		panic(fmt.Errorf("internal error: unhandled zero value for field type %v", ft.Kind))
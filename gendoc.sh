@@ -7,7 +7,7 @@ Command bstore provides commands for inspecting a bstore database.
 Subcommands:
 
 EOF
-go run cmd/bstore/bstore.go 2>&1 | sed 's/^/	/' | grep -v 'exit status'
+go run cmd/bstore/bstore.go cmd/bstore/genfields.go 2>&1 | sed 's/^./	&/' | grep -v 'exit status'
 echo '*/'
 echo 'package main'
 ) >cmd/bstore/doc.go
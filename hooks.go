@@ -0,0 +1,134 @@
+package bstore
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// Hooks are functions called during write operations on records of type T,
+// registered with SetHooks. Each hook is optional. Hooks are called in the
+// write transaction that modifies the record, and can use tx to read and write
+// other records, e.g. for maintaining denormalized counters, audit logs or
+// derived records.
+//
+// Hooks are called for the methods on Tx (including Upsert and InsertOrIgnore),
+// and for Query.Update* and Query.Delete. They are also called for records that
+// are deleted or updated due to a "cascade" or "setzero" reference action. Hooks
+// are not called by BulkLoad. Updates that do not change a record do not call
+// the update hooks.
+//
+// Before hooks are called before constraints are checked, and may modify the
+// new value, except for its primary key, which has already been assigned for
+// BeforeInsert. Changing the primary key results in an ErrParam error. After
+// hooks are called after the record has been written or deleted. Old values
+// must not be modified.
+//
+// An error returned by a hook is returned by the operation and marks the
+// transaction as botched, the hook may have made changes already.
+type Hooks[T any] struct {
+	BeforeInsert func(tx *Tx, v *T) error
+	AfterInsert  func(tx *Tx, v *T) error
+	BeforeUpdate func(tx *Tx, old, new *T) error
+	AfterUpdate  func(tx *Tx, old, new *T) error
+	BeforeDelete func(tx *Tx, old *T) error
+	AfterDelete  func(tx *Tx, old *T) error
+}
+
+// typeHooks are the untyped hooks for a type.
+type typeHooks struct {
+	beforeInsert, afterInsert func(tx *Tx, rv reflect.Value) error
+	beforeUpdate, afterUpdate func(tx *Tx, rov, rv reflect.Value) error
+	beforeDelete, afterDelete func(tx *Tx, rov reflect.Value) error
+}
+
+// SetHooks sets the hooks for type T, replacing any previously set hooks.
+// Passing a zero Hooks removes the hooks. T must be registered. SetHooks must
+// not be called while a transaction is active in the calling goroutine.
+func SetHooks[T any](db *DB, hooks Hooks[T]) error {
+	var zero T
+	t := reflect.TypeOf(zero)
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: type must be struct, not pointer or other type", ErrType)
+	}
+
+	db.typesMutex.Lock()
+	defer db.typesMutex.Unlock()
+
+	if _, ok := db.types[t]; !ok {
+		return fmt.Errorf("%w: %v", ErrType, t)
+	}
+
+	ptr := func(rv reflect.Value) *T {
+		return hookValue(rv).Interface().(*T)
+	}
+	var th typeHooks
+	var have bool
+	if fn := hooks.BeforeInsert; fn != nil {
+		th.beforeInsert = func(tx *Tx, rv reflect.Value) error { return fn(tx, ptr(rv)) }
+		have = true
+	}
+	if fn := hooks.AfterInsert; fn != nil {
+		th.afterInsert = func(tx *Tx, rv reflect.Value) error { return fn(tx, ptr(rv)) }
+		have = true
+	}
+	if fn := hooks.BeforeUpdate; fn != nil {
+		th.beforeUpdate = func(tx *Tx, rov, rv reflect.Value) error { return fn(tx, ptr(rov), ptr(rv)) }
+		have = true
+	}
+	if fn := hooks.AfterUpdate; fn != nil {
+		th.afterUpdate = func(tx *Tx, rov, rv reflect.Value) error { return fn(tx, ptr(rov), ptr(rv)) }
+		have = true
+	}
+	if fn := hooks.BeforeDelete; fn != nil {
+		th.beforeDelete = func(tx *Tx, rov reflect.Value) error { return fn(tx, ptr(rov)) }
+		have = true
+	}
+	if fn := hooks.AfterDelete; fn != nil {
+		th.afterDelete = func(tx *Tx, rov reflect.Value) error { return fn(tx, ptr(rov)) }
+		have = true
+	}
+
+	if !have {
+		delete(db.hooks, t)
+		return nil
+	}
+	if db.hooks == nil {
+		db.hooks = map[reflect.Type]*typeHooks{}
+	}
+	db.hooks[t] = &th
+	return nil
+}
+
+// hookValue returns a pointer to struct value rv, copying rv if it is not
+// addressable.
+func hookValue(rv reflect.Value) reflect.Value {
+	if rv.CanAddr() {
+		return rv.Addr()
+	}
+	nrv := reflect.New(rv.Type())
+	nrv.Elem().Set(rv)
+	return nrv
+}
+
+// callHook calls a hook, marking tx as botched if it fails.
+func (tx *Tx) callHook(name string, st storeType, fn func() error) (rerr error) {
+	defer tx.markError(&rerr)
+	if err := fn(); err != nil {
+		return fmt.Errorf("%s hook for %s: %w", name, st.Name, err)
+	}
+	return nil
+}
+
+// checkHookPK returns an error, marking tx as botched, if before hook name
+// changed the primary key of rv from k.
+func (tx *Tx) checkHookPK(name string, st storeType, rv reflect.Value, k []byte) (rerr error) {
+	defer tx.markError(&rerr)
+	pk, err := packPK(rv.FieldByIndex(st.Current.Fields[0].structField.Index))
+	if err != nil {
+		return err
+	} else if !bytes.Equal(pk, k) {
+		return fmt.Errorf("%w: %s hook for %s cannot change primary key", ErrParam, name, st.Name)
+	}
+	return nil
+}
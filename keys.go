@@ -1,6 +1,7 @@
 package bstore
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"math"
@@ -21,7 +22,12 @@ the index was created for, followed by the PK. The encoding of a field is nearly
 the same as the encoding of that type as a primary key. The differences: strings
 end with a \0 to make them self-delimiting; byte slices are not allowed because
 they are not self-delimiting; time.Time is allowed because the time is available
-in full (with timezone) in the record data.
+in full (with timezone) in the record data. Byte arrays are fixed width, and
+stored as is.
+
+Composite primary keys are structs with basic fields. They are encoded as the
+concatenation of their fields, each encoded as in an index key, so strings end
+with a \0. Such a key sorts by its fields in order.
 */
 
 // packPK returns the PK bytes representation for the PK value rv.
@@ -66,6 +72,13 @@ func packPK(rv reflect.Value) ([]byte, error) {
 	case uint64:
 		buf = binary.BigEndian.AppendUint64(nil, k)
 	default:
+		if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+			buf = make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(buf), rv)
+			break
+		} else if rv.Kind() == reflect.Struct {
+			return packKeyStruct(rv)
+		}
 		return nil, fmt.Errorf("%w: unsupported primary key type %T", ErrType, kv)
 	}
 	return buf, nil
@@ -86,6 +99,14 @@ func parsePK(rv reflect.Value, bk []byte) error {
 	case kindString:
 		rv.SetString(string(bk))
 		return nil
+	case kindArray:
+		if len(bk) != rv.Len() {
+			return fmt.Errorf("%w: got %d bytes for PK, need %d", ErrStore, len(bk), rv.Len())
+		}
+		reflect.Copy(rv, reflect.ValueOf(bk))
+		return nil
+	case kindStruct:
+		return parseKeyStruct(rv, bk)
 	}
 
 	var need int
@@ -184,6 +205,14 @@ fields:
 			take(8)
 		case kindTime:
 			take(8 + 4)
+		case kindArray:
+			take(ft.ArrayLength)
+		case kindStruct:
+			var n int
+			n, err = keyStructLen(ft, buf)
+			if err == nil {
+				take(n)
+			}
 		default:
 			err = fmt.Errorf("%w: unhandled kind %v for index key", ErrStore, ft.Kind)
 		}
@@ -332,8 +361,138 @@ func packIndexKey(frv reflect.Value) ([][]byte, error) {
 			bufs[i] = nbufs[0]
 		}
 		return bufs, nil
+	case kindArray:
+		if frv.Type().Elem().Kind() != reflect.Uint8 {
+			return nil, fmt.Errorf("internal error: bad array type %v for index", frv.Type())
+		}
+		buf = make([]byte, frv.Len())
+		reflect.Copy(reflect.ValueOf(buf), frv)
+	case kindStruct:
+		var err error
+		buf, err = packKeyStruct(frv)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, fmt.Errorf("internal error: bad type %v for index", frv.Type()) // todo: should be caught when making index type
 	}
 	return [][]byte{buf}, nil
 }
+
+// checkKeyStruct returns an error if struct type t cannot be used as composite
+// key: all fields must be exported, without struct tags, and of type bool,
+// integer or string.
+func checkKeyStruct(t reflect.Type) error {
+	if t.NumField() == 0 {
+		return fmt.Errorf("%w: composite key %v must have fields", ErrType, t)
+	}
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() || sf.Anonymous || sf.Tag.Get("bstore") != "" {
+			return fmt.Errorf("%w: field %q of composite key %v must be exported, not embedded, and without bstore struct tag", ErrType, sf.Name, t)
+		}
+		k, err := typeKind(sf.Type)
+		if err != nil {
+			return err
+		}
+		switch k {
+		case kindBool, kindInt8, kindInt16, kindInt32, kindInt64, kindInt, kindUint8, kindUint16, kindUint32, kindUint64, kindUint, kindString:
+		default:
+			return fmt.Errorf("%w: field %q of composite key %v must be bool, integer or string, not %v", ErrType, sf.Name, t, sf.Type)
+		}
+	}
+	return nil
+}
+
+// keyStructEqual returns whether composite keys a and b have the same encoding.
+func keyStructEqual(a, b fieldType) bool {
+	if a.Kind != kindStruct || b.Kind != kindStruct || len(a.structFields) != len(b.structFields) {
+		return false
+	}
+	for i, f := range a.structFields {
+		if f.Type.Kind != b.structFields[i].Type.Kind {
+			return false
+		}
+	}
+	return true
+}
+
+// packKeyStruct packs the fields of composite key rv.
+func packKeyStruct(rv reflect.Value) ([]byte, error) {
+	var buf []byte
+	for i := range rv.NumField() {
+		bufs, err := packIndexKey(rv.Field(i))
+		if err != nil {
+			return nil, fmt.Errorf("packing field %q of composite key: %w", rv.Type().Field(i).Name, err)
+		}
+		buf = append(buf, bufs[0]...)
+	}
+	return buf, nil
+}
+
+// parseKeyStruct parses composite key bk into struct rv.
+func parseKeyStruct(rv reflect.Value, bk []byte) error {
+	for i := range rv.NumField() {
+		frv := rv.Field(i)
+		k, err := typeKind(frv.Type())
+		if err != nil {
+			return err
+		}
+		n, err := keyValueLen(k, bk)
+		if err != nil {
+			return err
+		}
+		if k == kindString {
+			frv.SetString(string(bk[:n-1]))
+		} else if err := parsePK(frv, bk[:n]); err != nil {
+			return err
+		}
+		bk = bk[n:]
+	}
+	if len(bk) != 0 {
+		return fmt.Errorf("%w: leftover bytes in composite key (%x)", ErrStore, bk)
+	}
+	return nil
+}
+
+// keyStructLen returns the length of the packed composite key of type ft at the
+// start of buf.
+func keyStructLen(ft fieldType, buf []byte) (int, error) {
+	var o int
+	for _, f := range ft.structFields {
+		n, err := keyValueLen(f.Type.Kind, buf[o:])
+		if err != nil {
+			return 0, err
+		}
+		o += n
+	}
+	return o, nil
+}
+
+// keyValueLen returns the length of the packed key value of kind k at the start
+// of buf, including the \0 for strings.
+func keyValueLen(k kind, buf []byte) (int, error) {
+	var n int
+	switch k {
+	case kindString:
+		i := bytes.IndexByte(buf, 0)
+		if i < 0 {
+			return 0, fmt.Errorf("%w: bad string without 0 in key", ErrStore)
+		}
+		return i + 1, nil
+	case kindBool, kindInt8, kindUint8:
+		n = 1
+	case kindInt16, kindUint16:
+		n = 2
+	case kindInt32, kindUint32, kindInt, kindUint:
+		n = 4
+	case kindInt64, kindUint64:
+		n = 8
+	default:
+		return 0, fmt.Errorf("%w: unhandled kind %v for key", ErrStore, k)
+	}
+	if len(buf) < n {
+		return 0, fmt.Errorf("%w: not enough bytes in key", ErrStore)
+	}
+	return n, nil
+}
@@ -100,7 +100,7 @@ func TestKeys(t *testing.T) {
 	// Cannot use other types as PK.
 	tneedpkkey(t, ErrType, nil, Auto[time.Time]{time.Now()}, "")
 	tneedpkkey(t, ErrType, nil, Auto[[]string]{nil}, "")
-	tneedpkkey(t, ErrType, nil, Auto[Struct]{Struct{1}}, "")
+	tneedpkkey(t, ErrType, nil, Auto[struct{ F float64 }]{}, "") // Structs are composite keys, but not with floats.
 	tneedpkkey(t, ErrType, nil, Auto[Map]{Map{"a": 1}}, "")
 	tneedpkkey(t, ErrType, nil, Auto[[2]float32]{[...]float32{0, 0}}, "")
 
@@ -0,0 +1,125 @@
+package bstore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Migration is a named change to the database, e.g. a conversion of records
+// that bstore cannot do automatically during Register, run once by DB.Migrate.
+type Migration struct {
+	// Name identifies the migration, it is recorded in the database when Fn
+	// completes. It must not be changed once released.
+	Name string
+
+	// Fn is called with a write transaction. If it returns an error, the
+	// transaction is rolled back and the migration is not recorded.
+	Fn func(tx *Tx) error
+}
+
+// Migrate runs the migrations that have not yet completed, in order, each in
+// its own write transaction. Completed migrations are recorded in the
+// database, so each migration runs exactly once. New migrations must be
+// appended to the list.
+//
+// If the database has completed migrations that are not in the list, e.g.
+// because it was used by a newer version of the application, ErrMigration is
+// returned before running any migration.
+func (db *DB) Migrate(ctx context.Context, migrations ...Migration) error {
+	names := map[string]struct{}{}
+	for _, m := range migrations {
+		if m.Name == "" || m.Fn == nil {
+			return fmt.Errorf("%w: migration must have name and function", ErrParam)
+		}
+		if _, ok := names[m.Name]; ok {
+			return fmt.Errorf("%w: duplicate migration %q", ErrParam, m.Name)
+		}
+		names[m.Name] = struct{}{}
+	}
+
+	var done map[string]time.Time
+	err := db.Read(ctx, func(tx *Tx) error {
+		var err error
+		done, err = tx.Migrations()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	var unknown []string
+	for name := range done {
+		if _, ok := names[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("%w: database has completed migrations %q", ErrMigration, unknown)
+	}
+
+	for _, m := range migrations {
+		if _, ok := done[m.Name]; ok {
+			continue
+		}
+		err := db.Write(ctx, func(tx *Tx) error {
+			mb, err := tx.btx.CreateBucketIfNotExists([]byte(metaBucket))
+			if err != nil {
+				return fmt.Errorf("%w: creating meta bucket: %s", ErrStore, err)
+			}
+			b, err := mb.CreateBucketIfNotExists([]byte("migrations"))
+			if err != nil {
+				return fmt.Errorf("%w: creating migrations bucket: %s", ErrStore, err)
+			}
+			if b.Get([]byte(m.Name)) != nil {
+				// Completed in the meantime.
+				return nil
+			}
+			if err := m.Fn(tx); err != nil {
+				return err
+			}
+			if err := tx.error(); err != nil {
+				return err
+			}
+			v := tx.db.now().UTC().Format(time.RFC3339Nano)
+			if err := b.Put([]byte(m.Name), []byte(v)); err != nil {
+				return fmt.Errorf("%w: recording migration: %s", ErrStore, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("migration %q: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// Migrations returns the completed migrations with the time they completed.
+func (tx *Tx) Migrations() (map[string]time.Time, error) {
+	if err := tx.error(); err != nil {
+		return nil, err
+	}
+	var b *bolt.Bucket
+	if mb := tx.btx.Bucket([]byte(metaBucket)); mb != nil {
+		b = mb.Bucket([]byte("migrations"))
+	}
+	done := map[string]time.Time{}
+	if b == nil {
+		return done, nil
+	}
+	err := b.ForEach(func(k, v []byte) error {
+		tm, err := time.Parse(time.RFC3339Nano, string(v))
+		if err != nil {
+			return fmt.Errorf("%w: parsing time of migration %q: %v", ErrStore, k, err)
+		}
+		done[string(k)] = tm
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done, nil
+}
@@ -1,7 +1,6 @@
 package bstore
 
 import (
-	"fmt"
 	"reflect"
 )
 
@@ -233,7 +232,11 @@ func checkNonzeroRecords(tx *Tx, st storeType, tv *typeVersion, m map[reflect.Ty
 			return err
 		}
 		ct := m[st.Type]
-		return checkNonzeroFields(m, st.Type, ct.newlyNonzero, ct.fields, rv)
+		err = checkNonzeroFields(m, st.Type, ct.newlyNonzero, ct.fields, rv)
+		if cerr, ok := err.(*ConstraintError); ok {
+			cerr.Type = st.Name
+		}
+		return err
 	})
 }
 
@@ -244,7 +247,7 @@ func checkNonzeroFields(m map[reflect.Type]*nonzeroCheckType, t reflect.Type, ne
 	for _, f := range newlyNonzero {
 		frv := rv.FieldByIndex(f.structField.Index)
 		if f.Type.isZero(frv) {
-			return fmt.Errorf("%w: field %q", ErrZero, f.Name)
+			return &ConstraintError{Err: ErrZero, Field: f.Name}
 		}
 	}
 
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"strings"
 	"time"
 )
 
@@ -44,12 +45,33 @@ type packer struct {
 	fieldmaps []*fieldmap // Pending fieldmaps, not excluding fieldmap below.
 	fieldmap  *fieldmap   // Currently active.
 	popped    []*fieldmap // Completed fieldmaps, to be written back during finish.
+	path      []string    // Names of the struct fields being packed, for errors.
 }
 
 func (p *packer) errorf(format string, args ...any) {
 	panic(packErr{fmt.Errorf(format, args...)})
 }
 
+// zeroError aborts packing with an ErrZero ConstraintError for the field
+// currently being packed. The type name is set by storeType.pack.
+func (p *packer) zeroError() {
+	panic(packErr{&ConstraintError{Err: ErrZero, Field: strings.Join(p.path, ".")}})
+}
+
+// checkError aborts packing with an ErrCheck ConstraintError for value rv of
+// the field currently being packed.
+func (p *packer) checkError(rv reflect.Value) {
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	panic(packErr{&ConstraintError{Err: ErrCheck, Field: strings.Join(p.path, "."), Value: rv.Interface()}})
+}
+
+// discard returns a packer for packing values only for their nonzero checks.
+func (p *packer) discard() *packer {
+	return &packer{b: &bytes.Buffer{}, path: p.path}
+}
+
 // Push a new fieldmap on the stack for n fields.
 func (p *packer) PushFieldmap(n int) {
 	p.fieldmaps = append(p.fieldmaps, p.fieldmap)
@@ -130,6 +152,9 @@ func (st storeType) pack(rv reflect.Value) (rbuf []byte, rerr error) {
 		perr, ok := x.(packErr)
 		if ok {
 			rerr = perr.err
+			if cerr, ok := rerr.(*ConstraintError); ok {
+				cerr.Type = st.Name
+			}
 			return
 		}
 		panic(x)
@@ -146,21 +171,26 @@ func (tv typeVersion) pack(p *packer, rv reflect.Value) {
 	p.PushFieldmap(len(tv.Fields) - 1)
 
 	for _, f := range tv.Fields[1:] {
+		p.path = append(p.path, f.Name)
 		nrv := rv.FieldByIndex(f.structField.Index)
+		if f.Check != nil && !f.Check.valid(nrv) {
+			p.checkError(nrv)
+		}
 		if f.Type.isZero(nrv) {
 			if f.Nonzero {
-				p.errorf("%w: %q", ErrZero, f.Name)
+				p.zeroError()
 			}
 			p.Field(false)
 			// Pretend to pack to get the nonzero checks.
 			// todo: we should be able to do nonzero-check without pretending to pack.
 			if nrv.IsValid() && (nrv.Kind() != reflect.Ptr || !nrv.IsZero()) {
-				f.Type.pack(&packer{b: &bytes.Buffer{}}, nrv)
+				f.Type.pack(p.discard(), nrv)
 			}
 		} else {
 			p.Field(true)
 			f.Type.pack(p, nrv)
 		}
+		p.path = p.path[:len(p.path)-1]
 	}
 	p.PopFieldmap()
 }
@@ -228,7 +258,7 @@ func (ft fieldType) pack(p *packer, rv reflect.Value) {
 				p.Field(false)
 				// Pretend to pack to get the nonzero checks of the element.
 				if nrv.IsValid() && (nrv.Kind() != reflect.Ptr || !nrv.IsZero()) {
-					ft.ListElem.pack(&packer{b: &bytes.Buffer{}}, nrv)
+					ft.ListElem.pack(p.discard(), nrv)
 				}
 			} else {
 				p.Field(true)
@@ -245,7 +275,7 @@ func (ft fieldType) pack(p *packer, rv reflect.Value) {
 				p.Field(false)
 				// Pretend to pack to get the nonzero checks of the element.
 				if nrv.IsValid() && (nrv.Kind() != reflect.Ptr || !nrv.IsZero()) {
-					ft.ListElem.pack(&packer{b: &bytes.Buffer{}}, nrv)
+					ft.ListElem.pack(p.discard(), nrv)
 				}
 			} else {
 				p.Field(true)
@@ -268,7 +298,7 @@ func (ft fieldType) pack(p *packer, rv reflect.Value) {
 				p.Field(false)
 				// Pretend to pack to get the nonzero checks of the key type.
 				if v.IsValid() && (v.Kind() != reflect.Ptr || !v.IsZero()) {
-					ft.MapValue.pack(&packer{b: &bytes.Buffer{}}, v)
+					ft.MapValue.pack(p.discard(), v)
 				}
 			} else {
 				p.Field(true)
@@ -279,20 +309,25 @@ func (ft fieldType) pack(p *packer, rv reflect.Value) {
 	case kindStruct:
 		p.PushFieldmap(len(ft.structFields))
 		for _, f := range ft.structFields {
+			p.path = append(p.path, f.Name)
 			nrv := rv.FieldByIndex(f.structField.Index)
+			if f.Check != nil && !f.Check.valid(nrv) {
+				p.checkError(nrv)
+			}
 			if f.Type.isZero(nrv) {
 				if f.Nonzero {
-					p.errorf("%w: %q", ErrZero, f.Name)
+					p.zeroError()
 				}
 				p.Field(false)
 				// Pretend to pack to get the nonzero checks.
 				if nrv.IsValid() && (nrv.Kind() != reflect.Ptr || !nrv.IsZero()) {
-					f.Type.pack(&packer{b: &bytes.Buffer{}}, nrv)
+					f.Type.pack(p.discard(), nrv)
 				}
 			} else {
 				p.Field(true)
 				f.Type.pack(p, nrv)
 			}
+			p.path = p.path[:len(p.path)-1]
 		}
 		p.PopFieldmap()
 	default:
@@ -160,6 +160,15 @@ func (tv typeVersion) parse(p *parser, rv reflect.Value) {
 			}
 			continue
 		}
+		if f.convert {
+			frv := rv.FieldByIndex(f.structField.Index)
+			if !fm.Nonzero(i) {
+				frv.Set(reflect.Zero(f.structField.Type))
+			} else if err := convertValue(f.Type.parseValue(p), frv); err != nil {
+				p.Errorf("%w: converting field %q: %s", ErrIncompatible, f.Name, err)
+			}
+			continue
+		}
 		if fm.Nonzero(i) {
 			f.Type.parse(p, rv.FieldByIndex(f.structField.Index))
 		} else if f.Nonzero {
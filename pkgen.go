@@ -0,0 +1,109 @@
+package bstore
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Primary key generators for struct tag "auto <generator>", for string, []byte
+// and [16]byte primary keys.
+var pkGenerators = map[string]func(now time.Time) [16]byte{
+	"uuid":   newUUIDv4,
+	"uuidv7": newUUIDv7,
+	"ulid":   newULID,
+}
+
+// checkAutogen returns an error if generator name is not valid for primary key
+// type ft.
+func checkAutogen(name string, ft fieldType) error {
+	if _, ok := pkGenerators[name]; !ok {
+		return fmt.Errorf("%w: unknown primary key generator %q, must be uuid, uuidv7 or ulid", ErrType, name)
+	}
+	switch {
+	case ft.Kind == kindString, ft.Kind == kindBytes:
+	case ft.Kind == kindArray && ft.ArrayLength == 16 && ft.ListElem.Kind == kindUint8:
+	default:
+		return fmt.Errorf("%w: primary key generator %q requires string, []byte or [16]byte primary key, not %s", ErrType, name, ft.Kind)
+	}
+	return nil
+}
+
+// generatePK sets a new generated value in primary key rv. Strings are set to
+// the canonical text form, uuids as lower-case hex with dashes, ulids in
+// Crockford base32.
+func (tv *typeVersion) generatePK(rv reflect.Value, now time.Time) {
+	id := pkGenerators[tv.Autogen](now)
+	switch tv.Fields[0].Type.Kind {
+	case kindString:
+		if tv.Autogen == "ulid" {
+			rv.SetString(formatULID(id))
+		} else {
+			rv.SetString(formatUUID(id))
+		}
+	case kindBytes:
+		rv.SetBytes(id[:])
+	default:
+		reflect.Copy(rv, reflect.ValueOf(id[:]))
+	}
+}
+
+func randomBytes(buf []byte) {
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %v", err))
+	}
+}
+
+// newUUIDv4 returns a random UUID, RFC 9562 version 4.
+func newUUIDv4(now time.Time) [16]byte {
+	var id [16]byte
+	randomBytes(id[:])
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
+
+// newUUIDv7 returns a time-ordered UUID, RFC 9562 version 7, with millisecond
+// unix timestamp followed by random bits.
+func newUUIDv7(now time.Time) [16]byte {
+	id := newULID(now)
+	id[6] = id[6]&0x0f | 0x70
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
+
+// newULID returns a ULID, with a 48 bit millisecond unix timestamp followed by
+// 80 random bits. ULIDs generated within the same millisecond are not ordered.
+func newULID(now time.Time) [16]byte {
+	var id [16]byte
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(now.UnixMilli()))
+	copy(id[:6], ts[2:])
+	randomBytes(id[6:])
+	return id
+}
+
+func formatUUID(id [16]byte) string {
+	s := hex.EncodeToString(id[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// formatULID returns the 26 character Crockford base32 form of a ULID, which
+// sorts the same as the binary form.
+func formatULID(id [16]byte) string {
+	const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+	var buf [26]byte
+	// 130 bits of output for 128 bits of input, the first character holds the
+	// top 3 bits.
+	for i := 25; i >= 0; i-- {
+		buf[i] = alphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
@@ -1,6 +1,7 @@
 package bstore
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -802,7 +803,7 @@ func (q *Query[T]) filterCompare(fieldName string, op compareOp, value reflect.V
 	if !ok {
 		return q
 	}
-	if !comparable(ff.Type) {
+	if !q.orderable(ff) {
 		q.errorf("%w: cannot compare %s", ErrParam, ff.Type.Kind)
 		return q
 	}
@@ -857,7 +858,7 @@ func (q *Query[T]) order(fieldNames []string, asc bool) *Query[T] {
 		if !ok {
 			return q
 		}
-		if !comparable(ff.Type) {
+		if !q.orderable(ff) {
 			q.errorf("%w: cannot sort by unorderable %q", ErrParam, name)
 			return q
 		}
@@ -941,8 +942,24 @@ func (q *Query[T]) Delete() (deleted int, rerr error) {
 
 	n := 0
 	err := q.foreachKey(true, true, func(bk []byte, ov T) error {
-		n++
 		rov := reflect.ValueOf(ov)
+		if len(q.st.Current.referencedBy) > 0 || len(q.xtx.db.hooks) > 0 {
+			// Record may have been removed or modified by a cascading delete or hook of an
+			// earlier record, hooks of any type may change records. We need the stored
+			// value for removing index keys.
+			q.stats.Records.Get++
+			bv := q.exec.rb.Get(bk)
+			if bv == nil {
+				return nil
+			}
+			nrov, err := q.st.parseNew(bk, bv)
+			if err != nil {
+				return fmt.Errorf("parsing current value: %w", err)
+			}
+			rov = nrov
+			ov = rov.Interface().(T)
+		}
+		n++
 		q.gather(ov, rov)
 		q.stats.Delete++
 		return q.xtx.delete(q.exec.rb, q.st, bk, rov)
@@ -1095,20 +1112,105 @@ next:
 	return q.update(fields, values)
 }
 
+// UpdateFn calls fn for each selected record, with a pointer to the record
+// that fn can modify, and stores the modified record, returning the number of
+// records updated. Only indices for changed fields are updated, and
+// constraints are checked as with Tx.Update. The primary key cannot be
+// modified.
+//
+// If fn returns StopForEach, UpdateFn stops, does not store the record passed to
+// that call of fn, and returns nil. Other errors are returned.
+//
+// See Gather and GatherIDs for collecting the updated records or IDs.
+func (q *Query[T]) UpdateFn(fn func(value *T) error) (updated int, rerr error) {
+	defer q.finish(&rerr)
+	q.checkNotNext()
+	if !q.checkErr() {
+		return 0, q.err
+	}
+
+	if fn == nil {
+		return 0, fmt.Errorf("%w: nil fn", ErrParam)
+	}
+
+	n := 0
+	pkIndex := q.st.Current.Fields[0].structField.Index
+	for {
+		bk, _, err := q.nextKey(true, false)
+		if err == ErrAbsent {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+
+		// We parse the value from storage, the record may have been modified or
+		// removed by hooks for an earlier record. We parse the old value separately, fn
+		// can modify data shared with v, such as slices and maps.
+		q.stats.Records.Get++
+		bv := q.exec.rb.Get(bk)
+		if bv == nil {
+			if len(q.xtx.db.hooks) > 0 {
+				continue
+			}
+			return n, fmt.Errorf("%w: no data for key", ErrStore)
+		}
+		var v T
+		if err := q.st.parseFull(reflect.ValueOf(&v).Elem(), bk, bv); err != nil {
+			return n, fmt.Errorf("parsing current value: %w", err)
+		}
+		ov, err := q.st.parseNew(bk, bv)
+		if err != nil {
+			return n, fmt.Errorf("parsing current value: %w", err)
+		}
+
+		if err := fn(&v); err == StopForEach {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		rv := reflect.ValueOf(&v).Elem()
+		if pk, err := packPK(rv.FieldByIndex(pkIndex)); err != nil {
+			return n, err
+		} else if !bytes.Equal(pk, bk) {
+			return n, fmt.Errorf("%w: cannot update primary key", ErrParam)
+		}
+		n++
+		q.stats.Update++
+		if err := q.xtx.update(q.exec.rb, q.st, rv, ov, bk); err != nil {
+			return n, err
+		}
+		q.gather(v, rv)
+	}
+}
+
 func (q *Query[T]) update(fields []reflect.StructField, values []reflect.Value) (int, error) {
 	n := 0
 	ov := reflect.New(q.st.Type).Elem()
 	err := q.foreachKey(true, true, func(bk []byte, v T) error {
-		n++
 		rv := reflect.ValueOf(&v).Elem()
+		if len(q.xtx.db.hooks) > 0 {
+			// Hooks for an earlier record may have modified or removed this record.
+			q.stats.Records.Get++
+			bv := q.exec.rb.Get(bk)
+			if bv == nil {
+				return nil
+			}
+			if err := q.st.parseFull(rv, bk, bv); err != nil {
+				return fmt.Errorf("parsing current value: %w", err)
+			}
+		}
+		n++
 		ov.Set(rv)
 		for i, sf := range fields {
 			frv := rv.FieldByIndex(sf.Index)
 			frv.Set(values[i])
 		}
-		q.gather(v, rv)
 		q.stats.Update++
-		return q.xtx.update(q.exec.rb, q.st, rv, ov, bk)
+		if err := q.xtx.update(q.exec.rb, q.st, rv, ov, bk); err != nil {
+			return err
+		}
+		q.gather(v, rv)
+		return nil
 	})
 	return n, err
 }
@@ -421,6 +421,67 @@ func TestQuery(t *testing.T) {
 	tneed(t, err, ErrParam, "query without tx/db")
 }
 
+func TestQueryUpdateFn(t *testing.T) {
+	type User struct {
+		ID    int
+		Name  string   `bstore:"unique"`
+		Tags  []string `bstore:"index"`
+		Count int
+	}
+
+	const path = "testdata/tmp.queryupdatefn.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, User{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	u0 := User{Name: "a", Tags: []string{"x", "y"}}
+	u1 := User{Name: "b", Tags: []string{"x"}}
+	err = db.Insert(ctxbg, &u0, &u1)
+	tcheck(t, err, "insert")
+
+	var updated []User
+	n, err := QueryDB[User](ctxbg, db).Gather(&updated).UpdateFn(func(u *User) error {
+		// Modify slice in place, index must still be updated.
+		u.Tags[0] = "z"
+		u.Count = 0
+		return nil
+	})
+	tcompare(t, err, n, 2, "updatefn")
+	tcompare(t, err, updated, []User{{u0.ID, "a", []string{"z", "y"}, 0}, {u1.ID, "b", []string{"z"}, 0}}, "gathered")
+
+	n, err = QueryDB[User](ctxbg, db).FilterIn("Tags", "x").Count()
+	tcompare(t, err, n, 0, "old index values removed")
+	n, err = QueryDB[User](ctxbg, db).FilterIn("Tags", "z").Count()
+	tcompare(t, err, n, 2, "new index values")
+
+	_, err = QueryDB[User](ctxbg, db).FilterID(u1.ID).UpdateFn(func(u *User) error {
+		u.Name = "a"
+		return nil
+	})
+	tneed(t, err, ErrUnique, "unique violation")
+
+	_, err = QueryDB[User](ctxbg, db).FilterID(u1.ID).UpdateFn(func(u *User) error {
+		u.ID++
+		return nil
+	})
+	tneed(t, err, ErrParam, "modified primary key")
+
+	_, err = QueryDB[User](ctxbg, db).UpdateFn(nil)
+	tneed(t, err, ErrParam, "nil fn")
+
+	n, err = QueryDB[User](ctxbg, db).UpdateFn(func(u *User) error {
+		if u.ID == u1.ID {
+			return StopForEach
+		}
+		u.Count = 10
+		return nil
+	})
+	tcompare(t, err, n, 1, "updatefn with stop")
+	l, err := QueryDB[User](ctxbg, db).List()
+	tcompare(t, err, l, []User{{u0.ID, "a", []string{"z", "y"}, 10}, {u1.ID, "b", []string{"z"}, 0}}, "list after stop")
+}
+
 func TestQueryTime(t *testing.T) {
 	type User struct {
 		ID   int
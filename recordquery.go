@@ -0,0 +1,421 @@
+package bstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// RecordQuery selects records for Tx.QueryRecords.
+type RecordQuery struct {
+	Filters []RecordFilter // All filters must match.
+	Orders  []RecordOrder  // Sort order of the records, by field.
+	Limit   int            // If > 0, the maximum number of records.
+}
+
+// RecordFilter matches records on a field value.
+type RecordFilter struct {
+	// Name of the field, which must be of bool, integer, float, string, []byte or
+	// time.Time type, not a pointer.
+	Field string
+
+	// Comparison of the field value with Value: "=", "!=", "<", "<=", ">", ">=",
+	// or "in" for a Value that is a slice of values.
+	Op string
+
+	// Value to compare with. Converted to the type of the field, numbers must
+	// fit, e.g. float64 values from JSON. Values for time.Time fields can be
+	// strings in RFC3339 format.
+	Value any
+}
+
+// RecordOrder sorts records on a field, of a type allowed in RecordFilter.
+type RecordOrder struct {
+	Field string
+	Desc  bool
+}
+
+// recordFilter is a RecordFilter with values converted to the field type.
+type recordFilter struct {
+	f      field
+	op     string
+	values []reflect.Value // One value, or multiple for op "in".
+}
+
+// QueryRecords calls fn for each record of typeName matching q, parsed as map
+// like Records. The type does not have to be registered with Open or Register.
+// Fields is set to the fields of the type.
+//
+// Filters with "=" or "in" on the primary key fetch records directly. Otherwise
+// the index with the most leading fields with "=" or "in" filters is used, if
+// any. Otherwise all records are read, in primary key order. Records are sorted
+// in memory, unless only ordered by primary key.
+func (tx *Tx) QueryRecords(typeName string, q RecordQuery, fields *[]string, fn func(map[string]any) error) error {
+	versions, tv, rb, xfields, err := tx.db.prepareType(tx, typeName)
+	if err != nil {
+		return err
+	}
+	*fields = xfields
+
+	lookup := func(name string) (field, error) {
+		i := slices.IndexFunc(tv.Fields, func(f field) bool { return f.Name == name })
+		if i < 0 {
+			return field{}, fmt.Errorf("%w: unknown field %q", ErrParam, name)
+		}
+		f := tv.Fields[i]
+		if !comparable(f.Type) || f.Type.Kind == kindArray {
+			return field{}, fmt.Errorf("%w: cannot compare field %q of type %s", ErrParam, name, f.Type.typeString())
+		}
+		return f, nil
+	}
+
+	var filters []recordFilter
+	for _, rf := range q.Filters {
+		f, err := lookup(rf.Field)
+		if err != nil {
+			return err
+		}
+		var l []any
+		switch rf.Op {
+		case "=", "!=", "<", "<=", ">", ">=":
+			l = []any{rf.Value}
+		case "in":
+			v := reflect.ValueOf(rf.Value)
+			if v.Kind() != reflect.Slice {
+				return fmt.Errorf("%w: value for filter \"in\" on %q must be a slice", ErrParam, rf.Field)
+			}
+			for i := range v.Len() {
+				l = append(l, v.Index(i).Interface())
+			}
+		default:
+			return fmt.Errorf("%w: unknown filter operation %q", ErrParam, rf.Op)
+		}
+		ff := recordFilter{f: f, op: rf.Op}
+		for _, v := range l {
+			fv := reflect.New(reflect.TypeOf(f.Type.zeroExportValue())).Elem()
+			if err := setRecordValue(fv, v); err != nil {
+				return fmt.Errorf("filter on %q: %w", rf.Field, err)
+			}
+			ff.values = append(ff.values, fv)
+		}
+		filters = append(filters, ff)
+	}
+	var orders []field
+	for _, o := range q.Orders {
+		f, err := lookup(o.Field)
+		if err != nil {
+			return err
+		}
+		orders = append(orders, f)
+	}
+
+	tx.stats.Queries++
+	tx.stats.LastType = typeName
+	tx.stats.LastIndex = ""
+	tx.stats.LastOrdered = false
+
+	// Records are gathered if they must be sorted, otherwise passed to fn directly.
+	sorted := len(orders) > 0
+	var records []map[string]any
+	var n int
+	var pkAsc = true
+	if len(orders) == 1 && orders[0].Name == tv.Fields[0].Name {
+		sorted = false
+		pkAsc = !q.Orders[0].Desc
+	}
+
+	ctxDone := tx.ctx.Done()
+	seen := map[string]struct{}{} // Primary keys from index scans, "in" filters can match a record multiple times.
+	errLimit := fmt.Errorf("limit reached")
+
+	// Match record with primary key bk against filters. Called for each candidate record.
+	match := func(bk, bv []byte) error {
+		select {
+		case <-ctxDone:
+			return tx.ctx.Err()
+		default:
+		}
+
+		r, err := parseMap(versions, bk, bv)
+		if err != nil {
+			return err
+		}
+		for _, ff := range filters {
+			v, err := recordValue(r, ff.f)
+			if err != nil {
+				return err
+			}
+			if !ff.match(v) {
+				return nil
+			}
+		}
+		if sorted {
+			records = append(records, r)
+			return nil
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+		n++
+		if q.Limit > 0 && n >= q.Limit {
+			return errLimit
+		}
+		return nil
+	}
+
+	// Keys to fetch from the records bucket, or prefixes for index idx.
+	keys, idx, err := recordPlan(tv, filters)
+	if err != nil {
+		return err
+	}
+	if idx == nil && keys != nil {
+		tx.stats.PlanPK++
+		sort.Slice(keys, func(i, j int) bool {
+			c := bytes.Compare(keys[i], keys[j])
+			return pkAsc && c < 0 || !pkAsc && c > 0
+		})
+		for _, k := range keys {
+			if _, ok := seen[string(k)]; ok {
+				continue
+			}
+			seen[string(k)] = struct{}{}
+			tx.stats.Records.Get++
+			bv := rb.Get(k)
+			if bv == nil {
+				continue
+			}
+			if err := match(k, bv); err != nil {
+				return limitOK(err, errLimit)
+			}
+		}
+	} else if idx != nil {
+		tx.stats.PlanIndexScan++
+		tx.stats.LastIndex = idx.Name
+		ib, err := tx.typeBucket(typeName)
+		if err == nil {
+			ib = ib.Bucket([]byte("index." + idx.Name))
+			if ib == nil {
+				err = fmt.Errorf("%w: missing bucket for index %q", ErrStore, idx.Name)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		// The index is from a stored typeVersion, it needs its typeVersion for parsing keys.
+		xidx := *idx
+		xidx.tv = tv
+		var pks [][]byte
+		for _, prefix := range keys {
+			c := ib.Cursor()
+			tx.stats.Index.Cursor++
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+				tx.stats.Index.Cursor++
+				pk, _, err := xidx.parseKey(k, false, false)
+				if err != nil {
+					return err
+				}
+				if _, ok := seen[string(pk)]; !ok {
+					seen[string(pk)] = struct{}{}
+					pks = append(pks, pk)
+				}
+			}
+		}
+		if !sorted {
+			// Return records in primary key order, as for the other plans.
+			sort.Slice(pks, func(i, j int) bool {
+				c := bytes.Compare(pks[i], pks[j])
+				return pkAsc && c < 0 || !pkAsc && c > 0
+			})
+		}
+		for _, pk := range pks {
+			tx.stats.Records.Get++
+			bv := rb.Get(pk)
+			if bv == nil {
+				return fmt.Errorf("%w: missing record for index key", ErrStore)
+			}
+			if err := match(pk, bv); err != nil {
+				return limitOK(err, errLimit)
+			}
+		}
+	} else {
+		tx.stats.PlanTableScan++
+		if len(orders) == 1 && !sorted {
+			tx.stats.LastOrdered = true
+			tx.stats.LastAsc = pkAsc
+		}
+		c := rb.Cursor()
+		next := c.Next
+		first := c.First
+		if !pkAsc {
+			next = c.Prev
+			first = c.Last
+		}
+		for bk, bv := first(); bk != nil; bk, bv = next() {
+			tx.stats.Records.Cursor++
+			if err := match(bk, bv); err != nil {
+				return limitOK(err, errLimit)
+			}
+		}
+	}
+
+	if !sorted {
+		return nil
+	}
+
+	tx.stats.Sort++
+	var sortErr error
+	sort.SliceStable(records, func(i, j int) bool {
+		for k, f := range orders {
+			a, err := recordValue(records[i], f)
+			if err == nil {
+				var b reflect.Value
+				b, err = recordValue(records[j], f)
+				if err == nil {
+					if c := compare(f.Type.Kind, a, b); c != 0 {
+						return c < 0 != q.Orders[k].Desc
+					}
+					continue
+				}
+			}
+			sortErr = err
+			return false
+		}
+		return false
+	})
+	if sortErr != nil {
+		return sortErr
+	}
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+	for _, r := range records {
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// limitOK returns nil if err is errLimit.
+func limitOK(err, errLimit error) error {
+	if err == errLimit {
+		return nil
+	}
+	return err
+}
+
+// recordPlan returns the primary keys to fetch, or the index and key prefixes
+// to scan. If both are nil, all records must be read.
+func recordPlan(tv *typeVersion, filters []recordFilter) ([][]byte, *index, error) {
+	exact := map[string]*recordFilter{}
+	for i, ff := range filters {
+		if ff.op == "=" || ff.op == "in" {
+			exact[ff.f.Name] = &filters[i]
+		}
+	}
+
+	if ff := exact[tv.Fields[0].Name]; ff != nil {
+		var keys [][]byte
+		for _, v := range ff.values {
+			pk, err := packPK(v)
+			if err != nil {
+				return nil, nil, err
+			}
+			keys = append(keys, pk)
+		}
+		return keys, nil, nil
+	}
+
+	var best *index
+	var nbest int
+	for _, idx := range tv.Indices {
+		var n int
+		for _, f := range idx.Fields {
+			if exact[f.Name] == nil || f.Type.Kind == kindSlice {
+				break
+			}
+			n++
+		}
+		if n > nbest || n == nbest && n > 0 && idx.Name < best.Name {
+			best, nbest = idx, n
+		}
+	}
+	if best == nil {
+		return nil, nil, nil
+	}
+
+	// Multiply the prefixes with the values of each next field.
+	keys := [][]byte{{}}
+	for _, f := range best.Fields[:nbest] {
+		var nkeys [][]byte
+		for _, v := range exact[f.Name].values {
+			bufs, err := packIndexKey(v)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, k := range keys {
+				nkeys = append(nkeys, append(append([]byte{}, k...), bufs[0]...))
+			}
+		}
+		keys = nkeys
+	}
+	return keys, best, nil
+}
+
+// recordValue returns the value for field f in record r as parsed by parseMap,
+// with the type of the current field. Records of older typeVersions may not
+// have the field, or have it with a different type.
+func recordValue(r map[string]any, f field) (reflect.Value, error) {
+	t := reflect.TypeOf(f.Type.zeroExportValue())
+	v, ok := r[f.Name]
+	if !ok {
+		return reflect.Zero(t), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type() == t {
+		return rv, nil
+	}
+	nv := reflect.New(t).Elem()
+	if err := setRecordValue(nv, v); err != nil {
+		return reflect.Value{}, fmt.Errorf("%w: value of field %q: %v", ErrStore, f.Name, err)
+	}
+	return nv, nil
+}
+
+// match returns whether v matches the filter.
+func (ff recordFilter) match(v reflect.Value) bool {
+	if ff.op == "in" {
+		for _, fv := range ff.values {
+			if compare(ff.f.Type.Kind, v, fv) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	c := compare(ff.f.Type.Kind, v, ff.values[0])
+	switch ff.op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// QueryRecords calls QueryRecords on a new read-only Tx.
+func (db *DB) QueryRecords(ctx context.Context, typeName string, q RecordQuery, fields *[]string, fn func(map[string]any) error) error {
+	return db.Read(ctx, func(tx *Tx) error {
+		return tx.QueryRecords(typeName, q, fields, fn)
+	})
+}
@@ -8,8 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,9 +20,7 @@ import (
 	bolt "go.etcd.io/bbolt"
 )
 
-// todo: implement changing PK type, eg to wider int. requires rewriting all values, and removing old typeVersions.
-// todo: allow schema change between []byte and string?
-// todo: allow more schema changes, eg int to string, bool to int or string, int to bool, perhaps even string to int/bool. and between structs and maps. would require rewriting the records.
+// todo: allow more schema changes, eg between structs and maps. would require rewriting the records.
 
 const (
 	// First version.
@@ -35,23 +35,27 @@ const (
 var errSchemaCheck = errors.New("schema check")
 
 // Register registers the Go types of each value in typeValues for use with the
-// database. Each value must be a struct, not a pointer.
+// database. Each value must be a struct, not a pointer, or a *DynamicType.
 //
 // Type definition versions (schema versions) are added to the database if they
 // don't already exist or have changed. Existing type definitions are checked
 // for compatibility. Unique indexes are created if they don't already exist.
 // Creating a new unique index fails with ErrUnique on duplicate values.  If a
 // nonzero constraint is added, all records are verified to be nonzero. If a zero
-// value is found, ErrZero is returned.
+// value is found, ErrZero is returned. If Options.RegisterValidate was set
+// during Open, existing records of types with a changed schema are validated,
+// see Validator.
 //
 // Register can be called multiple times, with different types. But types that
 // reference each other must be registered in the same call to Registers.
 //
 // To help during development, if environment variable "bstore_schema_check" is set
 // to "changed", an error is returned if there is no schema change. If it is set to
-// "unchanged", an error is returned if there was a schema change.
+// "unchanged", an error is returned if there was a schema change. Use
+// RegisterDryRun to see which changes Register would make, and whether it
+// would fail.
 func (db *DB) Register(ctx context.Context, typeValues ...any) error {
-	return db.register(ctx, slog.New(discardHandler{}), typeValues...)
+	return db.register(ctx, slog.New(discardHandler{}), nil, typeValues...)
 }
 
 type discardHandler struct{}
@@ -61,7 +65,9 @@ func (l discardHandler) Handle(context.Context, slog.Record) error { return nil
 func (l discardHandler) WithAttrs(attrs []slog.Attr) slog.Handler  { return l }
 func (l discardHandler) WithGroup(name string) slog.Handler        { return l }
 
-func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any) error {
+// register registers typeValues. If diffs is not nil, the changes for each type
+// are appended to it, and the transaction is rolled back with errDryRun.
+func (db *DB) register(ctx context.Context, log *slog.Logger, diffs *[]SchemaDiff, typeValues ...any) error {
 	// We will drop/create new indices as needed. For changed indices, we drop
 	// and recreate. E.g. if an index becomes a unique index, or if a field in
 	// an index changes.  These values map type and index name to their index.
@@ -78,6 +84,9 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 	return db.Write(ctx, func(tx *Tx) error {
 		for _, t := range typeValues {
 			rt := reflect.TypeOf(t)
+			if dt, ok := t.(*DynamicType); ok {
+				rt = dt.rt
+			}
 			if rt.Kind() != reflect.Struct {
 				return fmt.Errorf("%w: type value %T is not a struct", ErrParam, t)
 			}
@@ -87,6 +96,10 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 				return fmt.Errorf("%w: generating schema for type %q", err, rt.Name())
 			}
 
+			if strings.HasPrefix(tv.name, ".") {
+				return fmt.Errorf("%w: type name %q cannot start with a dot", ErrType, tv.name)
+			}
+
 			// Ensure buckets exist.
 			tx.stats.Bucket.Get++
 			b := tx.btx.Bucket([]byte(tv.name))
@@ -150,6 +163,17 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 				return err
 			}
 
+			if err := tv.resolveOldNames(st.Current); err != nil {
+				return err
+			}
+
+			// Whether field types changed such that all records must be converted, and
+			// whether the primary key type changed, with the old primary key type.
+			var rewrite, rekey bool
+			var okey fieldType
+			// New fields whose default value is written into existing records.
+			var backfill []field
+
 			// Decide if we need to add a new typeVersion to the database. I.e. a new type schema.
 			if st.Current == nil || !st.Current.typeEqual(*tv) {
 				if checkSchemaUnchanged {
@@ -181,6 +205,10 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 					return fmt.Errorf("storing new schema: %w", err)
 				}
 
+				if diffs != nil {
+					*diffs = append(*diffs, schemaDiff(st.Current, tv, db.registerValidate))
+				}
+
 				if st.Current != nil {
 					// Copy current ReferencedBy, updated later and check for consistency.
 					tv.ReferencedBy = map[string]struct{}{}
@@ -191,9 +219,12 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 					// Indices can change: between index and unique, or fields.
 					// We recreate them for such changes.
 					recreateIndices := map[string]struct{}{}
-					if err := tx.checkTypes(st.Current, tv, recreateIndices); err != nil {
+					rewrite, rekey, err = tx.checkTypes(st.Current, tv, recreateIndices)
+					if err != nil {
 						return fmt.Errorf("checking compatibility of types: %w", err)
 					}
+					okey = st.Current.Fields[0].Type
+					backfill = backfillFields(st.Current, tv)
 					for iname := range recreateIndices {
 						ibname := fmt.Sprintf("index.%s", iname)
 						tx.stats.Bucket.Delete++
@@ -209,27 +240,11 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 					// If the current latest (old) primary key has "noauto", but
 					// the new version does not, we will ensure the records
 					// bucket sequence (that we use for autoincrement) is set to
-					// the highest value stored so far.
-					if st.Current.Noauto && !tv.Noauto {
-						tx.stats.Records.Cursor++
-						bk, _ := rb.Cursor().Last()
-						if bk != nil {
-							rv := reflect.New(tv.Fields[0].structField.Type).Elem()
-							if err := parsePK(rv, bk); err != nil {
-								return fmt.Errorf("parsing pk of last record to update autoincrement sequence: %w", err)
-							}
-							var seq uint64
-							switch tv.Fields[0].Type.Kind {
-							case kindInt8, kindInt16, kindInt32, kindInt64, kindInt:
-								seq = uint64(rv.Int())
-							case kindUint8, kindUint16, kindUint32, kindUint64, kindUint:
-								seq = rv.Uint()
-							default:
-								return fmt.Errorf("internal error: noauto on non-int primary key: %v", err)
-							}
-							if err := rb.SetSequence(seq); err != nil {
-								return fmt.Errorf("%w: updating autoincrement sequence after schema change: %s", ErrStore, err)
-							}
+					// the highest value stored so far. For a primary key type
+					// change, this is done after storing the records with new keys.
+					if st.Current.Noauto && !tv.Noauto && !rekey {
+						if err := tx.updateAutoSeq(tv, rb); err != nil {
+							return err
 						}
 					}
 				}
@@ -239,6 +254,9 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 				tv.Version = st.Current.Version
 				// Start out with the previous ReferencedBy. May be updated later.
 				tv.ReferencedBy = st.Current.ReferencedBy
+				if diffs != nil {
+					*diffs = append(*diffs, SchemaDiff{Type: tv.name, Version: tv.Version})
+				}
 			}
 
 			// Prepare types for parsing into the registered reflect.Type.
@@ -246,6 +264,33 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 
 			st.Current = tv
 			st.Versions[tv.Version] = tv
+
+			if rewrite {
+				log.Debug("rewriting records for type", slog.String("type", tv.name))
+				var okeyp *fieldType
+				if rekey {
+					okeyp = &okey
+				}
+				if err := tx.rewriteRecords(st, rb, okeyp); err != nil {
+					return fmt.Errorf("rewriting records for type %q: %w", tv.name, err)
+				}
+				if rekey && !tv.Noauto {
+					if err := tx.updateAutoSeq(tv, rb); err != nil {
+						return err
+					}
+				}
+			}
+
+			if len(backfill) > 0 {
+				log.Debug("backfilling default values for type", slog.String("type", tv.name))
+				if err := tx.backfillDefaults(st, rb, backfill); err != nil {
+					return fmt.Errorf("backfilling default values for type %q: %w", tv.name, err)
+				}
+			}
+
+			if err := tx.ensureSeqBuckets(b, rb, st); err != nil {
+				return err
+			}
 			db.typeNames[st.Name] = st
 			db.types[st.Type] = st
 			registered[st.Name] = &st
@@ -271,7 +316,7 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 					rtv := db.typeNames[ref].Current
 					k := f.Type.Kind
 					refk := rtv.Fields[0].Type.Kind
-					if k != refk {
+					if k != refk || k == kindStruct && !keyStructEqual(f.Type, rtv.Fields[0].Type) {
 						return fmt.Errorf("%w: %s.%s references %s.%s but fields have different types %s and %s", ErrType, tv.name, f.Name, rtv.name, rtv.Fields[0].Name, k, refk)
 					}
 					// todo: should check if an index on this field exists, regardless of name. safes us an index.
@@ -429,6 +474,17 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 			}
 		}
 
+		if diffs != nil {
+			// Types can get a new version for a changed ReferencedBy only.
+			for i := range *diffs {
+				d := &(*diffs)[i]
+				if v := registered[d.Type].Current.Version; v != d.Version {
+					d.Changed = true
+					d.Version = v
+				}
+			}
+		}
+
 		// Now that all ReferencedBy are up to date, verify that all referenced types were
 		// registered in this call.
 		// The whole point of this exercise is to catch a Register of a type that is
@@ -456,6 +512,31 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 			}
 		}
 
+		// Check that existing records satisfy new value constraints.
+		for _, tv := range ntypeversions {
+			otv, ok := otypeversions[tv.name]
+			if !ok || !newChecks(otv.Fields, tv.Fields) {
+				continue
+			}
+			log.Debug("checking existing records for new value constraints", slog.String("type", tv.name))
+			if err := tx.checkRecords(db.typeNames[tv.name]); err != nil {
+				return err
+			}
+		}
+
+		// Validate existing records of types with a new schema, if requested.
+		if db.registerValidate {
+			for _, tv := range ntypeversions {
+				if _, ok := otypeversions[tv.name]; !ok {
+					continue
+				}
+				log.Debug("validating existing records for new schema", slog.String("type", tv.name))
+				if err := tx.validateRecords(db.typeNames[tv.name]); err != nil {
+					return err
+				}
+			}
+		}
+
 		// Drop old/modified indices.
 		for name, tindices := range oindices {
 			for iname, idx := range tindices {
@@ -612,6 +693,10 @@ func (db *DB) register(ctx context.Context, log *slog.Logger, typeValues ...any)
 				ibkeys[i] = nil
 			}
 		}
+
+		if diffs != nil {
+			return errDryRun
+		}
 		return nil
 	})
 }
@@ -758,6 +843,15 @@ func gatherTypeVersion(t reflect.Type) (*typeVersion, error) {
 			return nil, fmt.Errorf("%w: cannot have noauto on non-integer primary key field", ErrType)
 		}
 	}
+	tv.Autogen, err = tags.Get("auto")
+	if err != nil {
+		return nil, err
+	}
+	if tv.Autogen != "" {
+		if err := checkAutogen(tv.Autogen, tv.Fields[0].Type); err != nil {
+			return nil, err
+		}
+	}
 
 	// Resolve structFields for the typeFields that reference an earlier defined type,
 	// using the same function as used when loading a type from disk.
@@ -786,6 +880,19 @@ func gatherTypeVersion(t reflect.Type) (*typeVersion, error) {
 			}
 			switch f.Type.Kind {
 			case kindBool, kindInt8, kindInt16, kindInt32, kindInt64, kindInt, kindUint8, kindUint16, kindUint32, kindUint64, kindUint, kindString, kindTime:
+			case kindArray:
+				// Byte arrays, e.g. for references to types with a [16]byte primary key.
+				if f.Type.ListElem.Kind != kindUint8 || f.Type.ListElem.Ptr {
+					return fmt.Errorf("%w: cannot use array of %v in field %q as index/unique", ErrType, f.Type.ListElem.Kind, f.Name)
+				}
+			case kindStruct:
+				// Composite keys, only for references to types with a composite primary key.
+				if len(f.References) == 0 {
+					return fmt.Errorf("%w: cannot use type %v in field %q as index/unique", ErrType, f.Type.Kind, f.Name)
+				}
+				if err := checkKeyStruct(f.structField.Type); err != nil {
+					return fmt.Errorf("%w: cannot use struct in field %q as index/unique: %v", ErrType, f.Name, err)
+				}
 			case kindSlice:
 				nslice++
 				if nslice > 1 {
@@ -876,11 +983,7 @@ func gatherTypeVersion(t reflect.Type) (*typeVersion, error) {
 	for i := range tv.Fields {
 		f := &tv.Fields[i]
 		refseen := map[string]struct{}{}
-		tags, err := newStoreTags(f.structField.Tag.Get("bstore"), i == 0)
-		if err != nil {
-			return nil, err
-		}
-		for _, name := range tags.List("ref") {
+		for _, name := range f.References {
 			if _, ok := refseen[name]; ok {
 				return nil, fmt.Errorf("%w: duplicate references %q in field %q", ErrType, name, f.Name)
 			}
@@ -967,9 +1070,17 @@ func gatherTypeFields(typeSeqs map[reflect.Type]int, t reflect.Type, needFirst,
 		// Parse a default value.
 		var def reflect.Value
 		defstr, err := tags.Get("default")
+		var backfill bool
 		if err != nil {
 			return nil, nil, fmt.Errorf("field %q: %w", sf.Name, err)
-		} else if defstr != "" {
+		} else if s, ok := strings.CutSuffix(defstr, " backfill"); ok {
+			if !topLevel {
+				return nil, nil, fmt.Errorf("%w: backfill of default value only allowed on fields of top-level struct, not on %q", ErrType, sf.Name)
+			}
+			defstr = s
+			backfill = true
+		}
+		if defstr != "" {
 			if inMap {
 				return nil, nil, fmt.Errorf("%w: cannot have default value inside a map value", ErrType)
 			}
@@ -1038,13 +1149,105 @@ func gatherTypeFields(typeSeqs map[reflect.Type]int, t reflect.Type, needFirst,
 			e := embed{name, ft, sf}
 			embedFields = append(embedFields, e)
 		} else {
-			f := field{name, ft, nonzero, tags.List("ref"), defstr, def, sf, false, nil}
+			refs, onDelete, err := parseRefs(tags.List("ref"))
+			if err != nil {
+				return nil, nil, fmt.Errorf("field %q: %w", sf.Name, err)
+			}
+			for _, action := range onDelete {
+				if nonzero && action == "setzero" {
+					return nil, nil, fmt.Errorf(`%w: field %q cannot have both nonzero and ref action "setzero"`, ErrType, sf.Name)
+				}
+			}
+			check, err := parseFieldCheck(tags, ft)
+			if err != nil {
+				return nil, nil, fmt.Errorf("field %q: %w", sf.Name, err)
+			}
+			var auto string
+			if tags.Has("autocreate") {
+				auto = "create"
+			}
+			if tags.Has("autoupdate") {
+				if auto != "" {
+					return nil, nil, fmt.Errorf("%w: field %q cannot have both autocreate and autoupdate", ErrType, sf.Name)
+				}
+				auto = "update"
+			}
+			if auto != "" && (!topLevel || ft.Kind != kindTime) {
+				return nil, nil, fmt.Errorf("%w: autocreate and autoupdate only allowed on time.Time fields of top-level struct, not on %q", ErrType, sf.Name)
+			}
+			version := tags.Has("version")
+			if version {
+				switch ft.Kind {
+				case kindInt, kindInt8, kindInt16, kindInt32, kindInt64, kindUint, kindUint8, kindUint16, kindUint32, kindUint64:
+				default:
+					return nil, nil, fmt.Errorf("%w: version field %q must be an integer", ErrType, sf.Name)
+				}
+				if !topLevel || ft.Ptr {
+					return nil, nil, fmt.Errorf("%w: version field %q must be a non-pointer field of the top-level struct", ErrType, sf.Name)
+				}
+				for _, of := range fields {
+					if of.Version {
+						return nil, nil, fmt.Errorf("%w: multiple version fields %q and %q", ErrType, of.Name, name)
+					}
+				}
+			}
+			var seq string
+			for _, w := range []string{"seq", "modseq"} {
+				if !tags.Has(w) {
+					continue
+				}
+				if seq != "" || version {
+					return nil, nil, fmt.Errorf("%w: field %q can have only one of seq, modseq and version", ErrType, sf.Name)
+				}
+				switch ft.Kind {
+				case kindInt, kindInt8, kindInt16, kindInt32, kindInt64, kindUint, kindUint8, kindUint16, kindUint32, kindUint64:
+				default:
+					return nil, nil, fmt.Errorf("%w: %s field %q must be an integer", ErrType, w, sf.Name)
+				}
+				if !topLevel || ft.Ptr {
+					return nil, nil, fmt.Errorf("%w: %s field %q must be a non-pointer field of the top-level struct", ErrType, w, sf.Name)
+				}
+				seq = w
+			}
+			oldname, err := tags.Get("oldname")
+			if err != nil {
+				return nil, nil, err
+			} else if oldname != "" && !topLevel {
+				return nil, nil, fmt.Errorf("%w: oldname only allowed on fields of the top-level struct, not on %q", ErrType, sf.Name)
+			}
+			f := field{name, ft, nonzero, refs, onDelete, defstr, check, auto, version, seq, oldname, def, sf, false, false, backfill, nil}
 			fields = append(fields, f)
 		}
 	}
 	return fields, embedFields, nil
 }
 
+// parseRefs parses the parameters of "ref" struct tags: a type name optionally
+// followed by an action to take when the referenced record is deleted.
+func parseRefs(l []string) ([]string, map[string]string, error) {
+	var refs []string
+	var onDelete map[string]string
+	for _, s := range l {
+		t := strings.Split(s, " ")
+		if len(t) > 2 {
+			return nil, nil, fmt.Errorf("%w: invalid ref, too many tokens in %q", ErrType, s)
+		}
+		if len(t) == 2 {
+			switch t[1] {
+			case "cascade", "setzero":
+			default:
+				return nil, nil, fmt.Errorf("%w: unknown ref action %q, must be cascade or setzero", ErrType, t[1])
+			}
+			if onDelete == nil {
+				onDelete = map[string]string{}
+			}
+			onDelete[t[0]] = t[1]
+		}
+		refs = append(refs, t[0])
+	}
+	return refs, onDelete, nil
+}
+
 // checkKeyType returns an error if the type is not valid for use as primary key.
 // similar to storeType.keyValue
 func checkKeyType(t reflect.Type) error {
@@ -1055,6 +1258,13 @@ func checkKeyType(t reflect.Type) error {
 	switch k {
 	case kindBytes, kindString, kindBool, kindInt, kindInt8, kindInt16, kindInt32, kindInt64, kindUint, kindUint8, kindUint16, kindUint32, kindUint64:
 		return nil
+	case kindArray:
+		// Only [16]byte, e.g. for generated UUIDs.
+		if t.Elem().Kind() == reflect.Uint8 && t.Len() == 16 {
+			return nil
+		}
+	case kindStruct:
+		return checkKeyStruct(t)
 	}
 	return fmt.Errorf("%w: type %v not valid for primary key", ErrType, t)
 }
@@ -1138,6 +1348,9 @@ func (st storeType) prepare(ntv *typeVersion) {
 	for _, tv := range l {
 		later = append(later, tv.Fields)
 	}
+	// The new typeVersion may not be in Versions yet. Renamed fields must be
+	// followed into it.
+	later = append(later, ntv.Fields)
 	for i, tv := range l {
 		tv.prepare(ntv, later[i+1:])
 	}
@@ -1146,11 +1359,11 @@ func (st storeType) prepare(ntv *typeVersion) {
 // prepare for use with parse.
 func (tv typeVersion) prepare(ntv *typeVersion, later [][]field) {
 	for i, f := range tv.Fields {
-		nlater, nmvlater, skip := lookupLater(f.Name, later)
+		nlater, nmvlater, name, skip := lookupLater(f.Name, later)
 		if skip {
 			continue
 		}
-		tv.Fields[i].prepare(ntv.Fields, nlater, nmvlater)
+		tv.Fields[i].prepare(name, ntv.Fields, nlater, nmvlater)
 	}
 }
 
@@ -1158,8 +1371,10 @@ func (tv typeVersion) prepare(ntv *typeVersion, later [][]field) {
 // If the named field disappears in a future field list, skip will be true.
 // Otherwise, in each future list of fields, the matching field is looked up and
 // returned. For map types, the returned first list is for keys and second list for
-// map values. For other types, only the first list is set.
-func lookupLater(name string, later [][]field) (nlater, nmvlater [][]field, skip bool) {
+// map values. For other types, only the first list is set. Fields renamed in a
+// later list, with OldName set, are followed. The name in the last list is
+// returned as lastName.
+func lookupLater(name string, later [][]field) (nlater, nmvlater [][]field, lastName string, skip bool) {
 	// If a later typeVersion did not have this field, we will not parse it into the
 	// latest reflect type. This is old data that was discarded with a typeVersion
 	// change.
@@ -1173,19 +1388,34 @@ tv:
 				continue tv
 			}
 		}
-		return nil, nil, true
+		for _, nf := range newerFields {
+			if nf.OldName == name {
+				name = nf.Name
+				n, nmv := nf.Type.laterFields()
+				nlater = append(nlater, n)
+				nmvlater = append(nmvlater, nmv)
+				continue tv
+			}
+		}
+		return nil, nil, "", true
 	}
-	return nlater, nmvlater, false
+	return nlater, nmvlater, name, false
 }
 
-func (f *field) prepare(nfields []field, later, mvlater [][]field) {
+// prepare links f to the field "name" in nfields, the fields of the current
+// typeVersion.
+func (f *field) prepare(name string, nfields []field, later, mvlater [][]field) {
 	if f.prepared {
 		return
 	}
 	f.prepared = true
 	for _, nf := range nfields {
-		if nf.Name == f.Name {
+		if nf.Name == name {
 			f.structField = nf.structField
+			if needsConversion(f.Type, nf.Type) {
+				f.convert = true
+				continue
+			}
 			f.Type.prepare(&nf.Type, later, mvlater)
 		}
 	}
@@ -1204,11 +1434,11 @@ func (ft fieldType) laterFields() (later, mvlater []field) {
 
 func (ft fieldType) prepare(nft *fieldType, later, mvlater [][]field) {
 	for i, f := range ft.structFields {
-		nlater, nmvlater, skip := lookupLater(f.Name, later)
+		nlater, nmvlater, name, skip := lookupLater(f.Name, later)
 		if skip {
 			continue
 		}
-		ft.structFields[i].prepare(nft.structFields, nlater, nmvlater)
+		ft.structFields[i].prepare(name, nft.structFields, nlater, nmvlater)
 	}
 	if ft.MapKey != nil {
 		ft.MapKey.prepare(nft.MapKey, later, nil)
@@ -1219,6 +1449,38 @@ func (ft fieldType) prepare(nft *fieldType, later, mvlater [][]field) {
 	}
 }
 
+// resolveOldNames keeps OldName for fields of tv only when they rename a field
+// of the current typeVersion otv, and otherwise takes OldName from otv, so a
+// rename is recorded once, in the typeVersion where it happens.
+func (tv *typeVersion) resolveOldNames(otv *typeVersion) error {
+	has := func(fields []field, name string) bool {
+		return slices.ContainsFunc(fields, func(f field) bool { return f.Name == name })
+	}
+	for i, f := range tv.Fields {
+		if f.OldName == "" {
+			continue
+		}
+		if has(tv.Fields, f.OldName) {
+			return fmt.Errorf("%w: oldname %q of field %q is the name of another field", ErrType, f.OldName, f.Name)
+		}
+		if otv == nil || !has(otv.Fields, f.OldName) || has(otv.Fields, f.Name) {
+			tv.Fields[i].OldName = ""
+		}
+	}
+	if otv == nil {
+		return nil
+	}
+	// Keep renames of the current typeVersion, it is used for parsing when the type
+	// did not otherwise change.
+	for i, f := range tv.Fields {
+		j := slices.IndexFunc(otv.Fields, func(of field) bool { return of.Name == f.Name })
+		if f.OldName == "" && j >= 0 {
+			tv.Fields[i].OldName = otv.Fields[j].OldName
+		}
+	}
+	return nil
+}
+
 // typeEqual compares two typeVersions, typically the current for a
 // storeType and a potential new typeVersion for a type that is being
 // registered.
@@ -1229,7 +1491,7 @@ func (tv typeVersion) typeEqual(ntv typeVersion) bool {
 	if tv.OndiskVersion != ntv.OndiskVersion {
 		return false
 	}
-	if tv.Noauto != ntv.Noauto {
+	if tv.Noauto != ntv.Noauto || tv.Autogen != ntv.Autogen {
 		return false
 	}
 	if len(tv.Fields) != len(ntv.Fields) {
@@ -1256,10 +1518,10 @@ func (tv typeVersion) typeEqual(ntv typeVersion) bool {
 }
 
 func (f field) typeEqual(nf field) bool {
-	if f.Name != nf.Name || !f.Type.typeEqual(nf.Type) || f.Nonzero != nf.Nonzero || f.Default != nf.Default {
+	if f.Name != nf.Name || !f.Type.typeEqual(nf.Type) || f.Nonzero != nf.Nonzero || f.Default != nf.Default || !f.Check.equal(nf.Check) || f.Auto != nf.Auto || f.Version != nf.Version || f.Seq != nf.Seq {
 		return false
 	}
-	if len(f.References) != len(nf.References) {
+	if len(f.References) != len(nf.References) || !maps.Equal(f.OnDelete, nf.OnDelete) {
 		return false
 	}
 	for i, s := range f.References {
@@ -1312,23 +1574,79 @@ func (idx *index) typeEqual(nidx *index) bool {
 	return true
 }
 
+// updateAutoSeq ensures the records bucket sequence, used for autoincrement of
+// integer primary keys, is at least the highest primary key stored.
+func (tx *Tx) updateAutoSeq(tv *typeVersion, rb *bolt.Bucket) error {
+	tx.stats.Records.Cursor++
+	bk, _ := rb.Cursor().Last()
+	if bk == nil {
+		return nil
+	}
+	rv := reflect.New(tv.Fields[0].structField.Type).Elem()
+	if err := parsePK(rv, bk); err != nil {
+		return fmt.Errorf("parsing pk of last record to update autoincrement sequence: %w", err)
+	}
+	var seq uint64
+	switch tv.Fields[0].Type.Kind {
+	case kindInt8, kindInt16, kindInt32, kindInt64, kindInt:
+		if rv.Int() < 0 {
+			return nil
+		}
+		seq = uint64(rv.Int())
+	case kindUint8, kindUint16, kindUint32, kindUint64, kindUint:
+		seq = rv.Uint()
+	default:
+		return nil
+	}
+	if seq <= rb.Sequence() {
+		return nil
+	}
+	if err := rb.SetSequence(seq); err != nil {
+		return fmt.Errorf("%w: updating autoincrement sequence after schema change: %s", ErrStore, err)
+	}
+	return nil
+}
+
 // checkTypes checks if typeVersions otv and ntv are consistent with
 // their field types. E.g. an int32 can be changed into an int64, but an int64 cannot
 // into an int32. Indices that need to be recreated (for an int width change) are
-// recorded in recreateIndices.
-func (tx *Tx) checkTypes(otv, ntv *typeVersion, recreateIndices map[string]struct{}) error {
+// recorded in recreateIndices. If fields of the top-level struct change type in a
+// way that requires converting values, e.g. from int to string, rewrite is
+// returned as true. If the primary key type changes, rewrite and rekey are
+// returned as true: records are stored under new keys and all indices, which
+// end with the primary key, are recreated.
+func (tx *Tx) checkTypes(otv, ntv *typeVersion, recreateIndices map[string]struct{}) (rewrite, rekey bool, rerr error) {
 	// Used to track that two nonzero FieldsTypeSeq have been checked, to prevent
 	// recursing while checking.
 	checked := map[[2]int]struct{}{}
 
-	for _, f := range ntv.Fields {
-		for _, of := range otv.Fields {
-			if f.Name != of.Name {
+	// Composite primary keys cannot change, existing records would be lost.
+	opk, npk := otv.Fields[0].Type, ntv.Fields[0].Type
+	if (opk.Kind == kindStruct || npk.Kind == kindStruct) && !keyStructEqual(opk, npk) {
+		return false, false, fmt.Errorf("%w: composite primary key %q cannot change", ErrIncompatible, ntv.Fields[0].Name)
+	} else if opk.Kind != npk.Kind {
+		if _, err := opk.compatible(npk, checked); err != nil && !convertible(opk, npk) {
+			return false, false, fmt.Errorf("%w: primary key %q: %s", ErrIncompatible, ntv.Fields[0].Name, err)
+		}
+		rewrite = true
+		rekey = true
+		for name := range otv.Indices {
+			recreateIndices[name] = struct{}{}
+		}
+	}
+
+	for _, f := range ntv.Fields[1:] {
+		for _, of := range otv.Fields[1:] {
+			if f.Name != of.Name && f.OldName != of.Name {
 				continue
 			}
-			increase, err := of.Type.compatible(f.Type, checked)
-			if err != nil {
-				return fmt.Errorf("%w: field %q: %s", ErrIncompatible, f.Name, err)
+			var increase bool
+			if convertible(of.Type, f.Type) {
+				// Records are rewritten, indices with the new values are created.
+				rewrite = true
+				increase = true
+			} else if increase, rerr = of.Type.compatible(f.Type, checked); rerr != nil {
+				return false, false, fmt.Errorf("%w: field %q: %s", ErrIncompatible, f.Name, rerr)
 			}
 			if increase {
 				// Indices involving this field need to be recreated. The indices are packed with fixed widths.
@@ -1344,7 +1662,7 @@ func (tx *Tx) checkTypes(otv, ntv *typeVersion, recreateIndices map[string]struc
 			break
 		}
 	}
-	return nil
+	return rewrite, rekey, nil
 }
 
 // compatible returns if ft and nft's types are compatible (with recursive checks
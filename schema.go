@@ -0,0 +1,231 @@
+package bstore
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Schema is a stored version of a type definition, as returned by Tx.Schemas.
+type Schema struct {
+	Type         string        // Name of the type.
+	Version      uint32        // Increased for each schema change.
+	Current      bool          // Whether this is the latest version, used for new records.
+	Records      int           // Number of records stored with this version.
+	Fields       []SchemaField // The first field is the primary key.
+	Indices      []SchemaIndex // Sorted by name.
+	ReferencedBy []string      // Names of types that reference this type, sorted.
+}
+
+// SchemaField is a field of a stored type definition.
+type SchemaField struct {
+	Name string
+
+	// Kind of the field, without pointer, e.g. "int64", "string", "bytes",
+	// "struct", "slice", "array", "map", "time", "binarymarshal".
+	Kind string
+
+	// Go-like type of the field, e.g. "*string", "[]int32", "[16]uint8",
+	// "map[string]struct", "time.Time".
+	Type string
+
+	// Struct tag words for the field, normalized, e.g. "nonzero", "default 3",
+	// "ref User cascade", "enum a b", "seq". For the primary key: "noauto" or
+	// "auto <generator>". Indices are in Schema.Indices.
+	Tags []string `json:",omitempty"`
+
+	// For struct types, including in pointers, slices, arrays and map values, the
+	// fields of the struct. Empty for a cyclic reference to a struct that is
+	// being described.
+	Fields []SchemaField `json:",omitempty"`
+}
+
+// SchemaIndex is an index of a stored type definition.
+type SchemaIndex struct {
+	Name   string
+	Unique bool
+	Fields []string
+}
+
+// Schemas returns all stored versions of the type definition of typeName,
+// ordered by version, with the number of records stored per version. The type
+// does not have to be registered with Open or Register. Older versions are kept
+// until all records using them are rewritten, e.g. with UpgradeRecords.
+func (tx *Tx) Schemas(typeName string) ([]Schema, error) {
+	versions, tv, rb, _, err := tx.db.prepareType(tx, typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[uint32]int{}
+	ctxDone := tx.ctx.Done()
+	err = rb.ForEach(func(bk, bv []byte) error {
+		tx.stats.Records.Cursor++
+
+		select {
+		case <-ctxDone:
+			return tx.ctx.Err()
+		default:
+		}
+
+		version, n := binary.Uvarint(bv)
+		if n <= 0 {
+			return fmt.Errorf("%w: reading type version of record", ErrStore)
+		}
+		counts[uint32(version)]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var l []Schema
+	for _, xtv := range versions {
+		s := Schema{
+			Type:    typeName,
+			Version: xtv.Version,
+			Current: xtv == tv,
+			Records: counts[xtv.Version],
+			Fields:  schemaFields(xtv.Fields, map[int]bool{1: true}),
+		}
+		if xtv.Noauto {
+			s.Fields[0].Tags = append(s.Fields[0].Tags, "noauto")
+		}
+		if xtv.Autogen != "" {
+			s.Fields[0].Tags = append(s.Fields[0].Tags, "auto "+xtv.Autogen)
+		}
+		for _, idx := range xtv.Indices {
+			si := SchemaIndex{Name: idx.Name, Unique: idx.Unique}
+			for _, f := range idx.Fields {
+				si.Fields = append(si.Fields, f.Name)
+			}
+			s.Indices = append(s.Indices, si)
+		}
+		slices.SortFunc(s.Indices, func(a, b SchemaIndex) int { return strings.Compare(a.Name, b.Name) })
+		for name := range xtv.ReferencedBy {
+			s.ReferencedBy = append(s.ReferencedBy, name)
+		}
+		slices.Sort(s.ReferencedBy)
+		l = append(l, s)
+	}
+	slices.SortFunc(l, func(a, b Schema) int { return cmp.Compare(a.Version, b.Version) })
+	return l, nil
+}
+
+// schemaFields describes fields. Struct types with sequence numbers in active
+// are being described, and are not described again for cyclic types.
+func schemaFields(fields []field, active map[int]bool) []SchemaField {
+	var l []SchemaField
+	for _, f := range fields {
+		sf := SchemaField{
+			Name: f.Name,
+			Kind: string(f.Type.Kind),
+			Type: f.Type.typeString(),
+			Tags: f.tagWords(),
+		}
+		if st := f.Type.structType(); st != nil {
+			seq := st.FieldsTypeSeq
+			if seq < 0 {
+				seq = -seq
+			}
+			if seq == 0 {
+				// Older on-disk format, without cyclic types.
+				sf.Fields = schemaFields(st.structFields, active)
+			} else if !active[seq] {
+				active[seq] = true
+				sf.Fields = schemaFields(st.structFields, active)
+				delete(active, seq)
+			}
+		}
+		l = append(l, sf)
+	}
+	return l
+}
+
+// structType returns the struct type of ft, possibly through slices, arrays
+// and map values, or nil.
+func (ft fieldType) structType() *fieldType {
+	switch ft.Kind {
+	case kindStruct:
+		return &ft
+	case kindSlice, kindArray:
+		return ft.ListElem.structType()
+	case kindMap:
+		return ft.MapValue.structType()
+	}
+	return nil
+}
+
+// typeString returns a Go-like description of the type.
+func (ft fieldType) typeString() string {
+	var s string
+	switch ft.Kind {
+	case kindBytes:
+		s = "[]byte"
+	case kindSlice:
+		s = "[]" + ft.ListElem.typeString()
+	case kindArray:
+		s = fmt.Sprintf("[%d]%s", ft.ArrayLength, ft.ListElem.typeString())
+	case kindMap:
+		s = "map[" + ft.MapKey.typeString() + "]" + ft.MapValue.typeString()
+	case kindTime:
+		s = "time.Time"
+	default:
+		s = string(ft.Kind)
+	}
+	if ft.Ptr {
+		s = "*" + s
+	}
+	return s
+}
+
+// tagWords returns the struct tag words for the stored properties of f, except
+// for indices.
+func (f field) tagWords() []string {
+	var l []string
+	if f.Nonzero {
+		l = append(l, "nonzero")
+	}
+	for _, ref := range f.References {
+		if action := f.OnDelete[ref]; action != "" {
+			l = append(l, "ref "+ref+" "+action)
+		} else {
+			l = append(l, "ref "+ref)
+		}
+	}
+	if f.Default != "" {
+		l = append(l, "default "+f.Default)
+	}
+	if c := f.Check; c != nil {
+		if len(c.Enum) > 0 {
+			l = append(l, "enum "+strings.Join(c.Enum, " "))
+		}
+		if c.Min != "" {
+			l = append(l, "min "+c.Min)
+		}
+		if c.Max != "" {
+			l = append(l, "max "+c.Max)
+		}
+		if c.MaxLen != 0 {
+			l = append(l, fmt.Sprintf("maxlen %d", c.MaxLen))
+		}
+		if c.Pattern != "" {
+			l = append(l, "pattern "+c.Pattern)
+		}
+	}
+	if f.Auto != "" {
+		l = append(l, "auto"+f.Auto)
+	}
+	if f.Version {
+		l = append(l, "version")
+	}
+	if f.Seq != "" {
+		l = append(l, f.Seq)
+	}
+	if f.OldName != "" {
+		l = append(l, "oldname "+f.OldName)
+	}
+	return l
+}
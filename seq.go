@@ -0,0 +1,172 @@
+package bstore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// metaBucket is the top-level bolt bucket for data that is not specific to a
+// type, such as named counters and completed migrations. Type names cannot
+// start with a dot.
+const metaBucket = ".bstore"
+
+// NextSeq increments the named counter and returns its new value. The first
+// value is 1. Counters are stored in the database, independent of types, and
+// are only modified when tx is committed. The transaction must be writable.
+func (tx *Tx) NextSeq(name string) (uint64, error) {
+	if err := tx.error(); err != nil {
+		return 0, err
+	}
+	if name == "" {
+		return 0, fmt.Errorf("%w: empty counter name", ErrParam)
+	}
+	if !tx.btx.Writable() {
+		return 0, fmt.Errorf("%w: counter requires writable transaction", ErrParam)
+	}
+	mb, err := tx.btx.CreateBucketIfNotExists([]byte(metaBucket))
+	if err != nil {
+		return 0, fmt.Errorf("%w: creating meta bucket: %s", ErrStore, err)
+	}
+	cb, err := mb.CreateBucketIfNotExists([]byte("counters"))
+	if err != nil {
+		return 0, fmt.Errorf("%w: creating counters bucket: %s", ErrStore, err)
+	}
+	b, err := cb.CreateBucketIfNotExists([]byte(name))
+	if err != nil {
+		return 0, fmt.Errorf("%w: creating counter bucket: %s", ErrStore, err)
+	}
+	return b.NextSequence()
+}
+
+// Seq returns the current value of the named counter, 0 if it has not been
+// used.
+func (tx *Tx) Seq(name string) (uint64, error) {
+	if err := tx.error(); err != nil {
+		return 0, err
+	}
+	b := tx.btx.Bucket([]byte(metaBucket))
+	if b != nil {
+		b = b.Bucket([]byte("counters"))
+	}
+	if b != nil {
+		b = b.Bucket([]byte(name))
+	}
+	if b == nil {
+		return 0, nil
+	}
+	return b.Sequence(), nil
+}
+
+// NextSeq increments the named counter in a new write transaction, see
+// Tx.NextSeq.
+func (db *DB) NextSeq(ctx context.Context, name string) (v uint64, rerr error) {
+	rerr = db.Write(ctx, func(tx *Tx) error {
+		var err error
+		v, err = tx.NextSeq(name)
+		return err
+	})
+	if rerr != nil {
+		return 0, rerr
+	}
+	return v, nil
+}
+
+// seqBucketName returns the name of the subbucket of a type, holding the
+// sequence for a field with struct tag "seq" or "modseq".
+func seqBucketName(f field) string {
+	return "seq." + f.Name
+}
+
+// assignSeqs assigns the next sequence to fields of rv with struct tag "seq"
+// (only on insert, if zero) or "modseq" (on insert and update).
+func (tx *Tx) assignSeqs(tv *typeVersion, rv reflect.Value, insert bool) error {
+	for _, f := range tv.Fields[1:] {
+		if f.Seq == "" || f.Seq == "seq" && !insert {
+			continue
+		}
+		b, err := tx.bucket(bucketKey{tv.name, seqBucketName(f)})
+		if err != nil {
+			return err
+		}
+		fv := rv.FieldByIndex(f.structField.Index)
+		if f.Seq == "seq" && !fv.IsZero() {
+			// Continue after explicitly set values, like the primary key.
+			var v uint64
+			if fv.CanInt() {
+				if fv.Int() > 0 {
+					v = uint64(fv.Int())
+				}
+			} else {
+				v = fv.Uint()
+			}
+			if v > b.Sequence() {
+				if err := b.SetSequence(v); err != nil {
+					return fmt.Errorf("%w: updating sequence for field %q: %s", ErrStore, f.Name, err)
+				}
+			}
+			continue
+		}
+		v, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("%w: next sequence for field %q: %s", ErrStore, f.Name, err)
+		}
+		if fv.CanInt() {
+			if int64(v) < 0 || fv.OverflowInt(int64(v)) {
+				return fmt.Errorf("%w: next sequence for field %q does not fit in type", ErrSeq, f.Name)
+			}
+			fv.SetInt(int64(v))
+		} else {
+			if fv.OverflowUint(v) {
+				return fmt.Errorf("%w: next sequence for field %q does not fit in type", ErrSeq, f.Name)
+			}
+			fv.SetUint(v)
+		}
+	}
+	return nil
+}
+
+// ensureSeqBuckets creates the sequence buckets for fields of st with struct
+// tag "seq" or "modseq" in type bucket b. For new buckets, the sequence is set
+// to the highest value in the existing records.
+func (tx *Tx) ensureSeqBuckets(b, rb *bolt.Bucket, st storeType) error {
+	for _, f := range st.Current.Fields[1:] {
+		if f.Seq == "" {
+			continue
+		}
+		name := seqBucketName(f)
+		tx.stats.Bucket.Get++
+		if b.Bucket([]byte(name)) != nil {
+			continue
+		}
+		tx.stats.Bucket.Put++
+		sb, err := b.CreateBucket([]byte(name))
+		if err != nil {
+			return fmt.Errorf("%w: creating sequence bucket for field %q: %s", ErrStore, f.Name, err)
+		}
+		var max uint64
+		err = rb.ForEach(func(bk, bv []byte) error {
+			tx.stats.Records.Cursor++
+			rv, err := st.parseNew(bk, bv)
+			if err != nil {
+				return err
+			}
+			fv := rv.FieldByIndex(f.structField.Index)
+			if fv.CanInt() && fv.Int() > 0 && uint64(fv.Int()) > max {
+				max = uint64(fv.Int())
+			} else if fv.CanUint() && fv.Uint() > max {
+				max = fv.Uint()
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("finding highest value for sequence of field %q: %w", f.Name, err)
+		}
+		if err := sb.SetSequence(max); err != nil {
+			return fmt.Errorf("%w: setting sequence for field %q: %s", ErrStore, f.Name, err)
+		}
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"log/slog"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,12 +27,12 @@ import (
 - todo: should we have a function that returns records in a map? eg Map() that is like List() but maps a key to T (too bad we cannot have a type for the key!).
 - todo: better error messages (ordering of description & error; mention typename, fields (path), field types and offending value & type more often)
 - todo: should we add types for dates and numerics?
-- todo: struct tag for enums? where we check if the values match.
 */
 
 var (
 	ErrAbsent       = errors.New("absent") // If a function can return an ErrAbsent, it can be compared directly, without errors.Is.
 	ErrZero         = errors.New("must be nonzero")
+	ErrCheck        = errors.New("value not allowed") // For values violating "enum", "min", "max", "maxlen" or "pattern" struct tags.
 	ErrUnique       = errors.New("not unique")
 	ErrReference    = errors.New("referential inconsistency")
 	ErrMultiple     = errors.New("multiple results")
@@ -42,11 +43,54 @@ var (
 	ErrStore        = errors.New("internal/storage error") // E.g. when buckets disappear, possibly by external users of the underlying BoltDB database.
 	ErrParam        = errors.New("bad parameters")
 	ErrTxBotched    = errors.New("botched transaction") // Set on transactions after failed and aborted write operations.
+	ErrVersion      = errors.New("version mismatch")    // Update of a record with a "version" field that is different from the stored record.
+	ErrMigration    = errors.New("unknown migration")   // Database has completed migrations that the application does not know about, e.g. after a downgrade.
 
 	errTxClosed    = errors.New("transaction is closed")
 	errNestedIndex = errors.New("struct tags index/unique only allowed at top-level structs")
 )
 
+// ConstraintError is returned for violations of unique, reference, nonzero,
+// value check and version constraints. It matches ErrUnique, ErrReference,
+// ErrZero, ErrCheck or ErrVersion with errors.Is, and can be inspected with
+// errors.As, e.g. to construct an API error response.
+//
+// For ErrReference, the constraint is described from the referencing side:
+// Type and Field are the referencing type and field, also when the error is
+// the result of deleting a record that is still referenced.
+type ConstraintError struct {
+	Err        error  // ErrUnique, ErrReference, ErrZero, ErrCheck or ErrVersion.
+	Type       string // Name of the type, as stored in the database.
+	Field      string // Field name. For fields in nested structs, a dot-separated path. For unique indices on multiple fields, the field names separated by "+".
+	Index      string // Name of the index, for ErrUnique, and for ErrReference when deleting a referenced record.
+	Value      any    // Offending value. For unique indices on multiple fields, a []any with a value per field. Nil for ErrZero. For ErrVersion, the stored version.
+	Referenced string // Name of the referenced type, for ErrReference.
+}
+
+func (e *ConstraintError) Error() string {
+	var l []string
+	if e.Type != "" {
+		l = append(l, fmt.Sprintf("type %q", e.Type))
+	}
+	if e.Field != "" {
+		l = append(l, fmt.Sprintf("field %q", e.Field))
+	}
+	if e.Index != "" {
+		l = append(l, fmt.Sprintf("index %q", e.Index))
+	}
+	if e.Value != nil {
+		l = append(l, fmt.Sprintf("value %v", e.Value))
+	}
+	if e.Referenced != "" {
+		l = append(l, fmt.Sprintf("referenced type %q", e.Referenced))
+	}
+	return fmt.Sprintf("%s: %s", e.Err, strings.Join(l, ", "))
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
+
 var sanityChecks bool // Only enabled during tests.
 
 // DB is a database storing Go struct values in an underlying bolt database.
@@ -62,6 +106,10 @@ type DB struct {
 
 	statsMutex sync.Mutex
 	stats      Stats
+
+	registerValidate bool                        // From Options.RegisterValidate.
+	clock            func() time.Time            // From Options.Now.
+	hooks            map[reflect.Type]*typeHooks // Set with SetHooks, protected by typesMutex.
 }
 
 // Tx is a transaction on DB.
@@ -119,6 +167,7 @@ type typeVersion struct {
 	Version       uint32              // First uvarint of a stored record references this version.
 	OndiskVersion uint32              // Version of on-disk format. Currently always 1.
 	Noauto        bool                // If true, the primary key is an int but opted out of autoincrement.
+	Autogen       string              `json:",omitempty"` // Generator for zero primary keys on insert, from struct tag "auto <generator>": "uuid", "uuidv7" or "ulid".
 	Fields        []field             // Fields that we store. Embed/anonymous fields are kept separately in embedFields, and are not stored.
 	Indices       map[string]*index   // By name of index.
 	ReferencedBy  map[string]struct{} // Type names that reference this type. We require they are registered at the same time to maintain referential integrity.
@@ -136,9 +185,15 @@ type typeVersion struct {
 type field struct {
 	Name       string
 	Type       fieldType
-	Nonzero    bool     `json:",omitempty"`
-	References []string `json:",omitempty"` // Referenced fields. Only for the top-level struct fields, not for nested structs.
-	Default    string   `json:",omitempty"` // As specified in struct tag. Processed version is defaultValue.
+	Nonzero    bool              `json:",omitempty"`
+	References []string          `json:",omitempty"` // Referenced fields. Only for the top-level struct fields, not for nested structs.
+	OnDelete   map[string]string `json:",omitempty"` // By referenced type name, action when a referenced record is deleted: "cascade" or "setzero". If absent, deleting a referenced record fails.
+	Default    string            `json:",omitempty"` // As specified in struct tag. Processed version is defaultValue.
+	Check      *fieldCheck       `json:",omitempty"` // Value constraints from struct tags "enum", "min", "max", "maxlen" and "pattern".
+	Auto       string            `json:",omitempty"` // "create" or "update" for time fields with struct tag "autocreate" or "autoupdate".
+	Version    bool              `json:",omitempty"` // Integer field with struct tag "version", for optimistic concurrency control.
+	Seq        string            `json:",omitempty"` // "seq" or "modseq" for integer fields with that struct tag, assigned from a sequence.
+	OldName    string            `json:",omitempty"` // From struct tag "oldname", name of the field in the previous typeVersion if it was renamed. Only for top-level fields.
 
 	// If not the zero reflect.Value, set this value instead of a zero value on insert.
 	// This is always a non-pointer value. Only set for the current typeVersion
@@ -153,6 +208,12 @@ type field struct {
 	// Whether this field has been prepared for parsing into, i.e.
 	// structField set if needed.
 	prepared bool
+	// Whether stored values must be converted to the type of structField, for
+	// fields of older typeVersions, see convertValue.
+	convert bool
+	// Whether the default value is written into existing records when the field
+	// is added, from struct tag "default <value> backfill". Not stored.
+	backfill bool
 
 	indices map[string]*index
 }
@@ -296,6 +357,19 @@ type Options struct {
 	Perm           fs.FileMode   // Permissions for new file if created. If zero, 0600 is used.
 	MustExist      bool          // Before opening, check that file exists. If not, io/fs.ErrNotExist is returned.
 	RegisterLogger *slog.Logger  // For debug logging about schema upgrades.
+
+	// Now returns the current time, used for "default now", "autocreate" and
+	// "autoupdate" struct tags. Useful for deterministic tests. If nil, time.Now is
+	// used. Monotonic clock readings are stripped.
+	Now func() time.Time
+
+	// During Open/Register, call BstoreValidate on all existing records of types
+	// that implement Validator and that have a changed schema. See Validator.
+	RegisterValidate bool
+
+	// Migrations to run with DB.Migrate after registering types during Open. Open
+	// fails if the database has completed migrations that are not in this list.
+	Migrations []Migration
 }
 
 // Open opens a bstore database and registers types by calling Register.
@@ -340,19 +414,35 @@ func Open(ctx context.Context, path string, opts *Options, typeValues ...any) (*
 	var log *slog.Logger
 	if opts != nil {
 		log = opts.RegisterLogger
+		db.registerValidate = opts.RegisterValidate
+		db.clock = opts.Now
 	}
 	if log == nil {
 		log = slog.New(discardHandler{})
 	} else {
 		log = log.With("dbpath", path)
 	}
-	if err := db.register(ctx, log, typeValues...); err != nil {
+	if err := db.register(ctx, log, nil, typeValues...); err != nil {
 		bdb.Close()
 		return nil, err
 	}
+	if opts != nil && opts.Migrations != nil {
+		if err := db.Migrate(ctx, opts.Migrations...); err != nil {
+			bdb.Close()
+			return nil, err
+		}
+	}
 	return db, nil
 }
 
+// now returns the current time according to the clock from Options.Now.
+func (db *DB) now() time.Time {
+	if db.clock != nil {
+		return db.clock().Round(0)
+	}
+	return time.Now().Round(0)
+}
+
 // Close closes the underlying database.
 func (db *DB) Close() error {
 	return db.bdb.Close()
@@ -494,6 +584,34 @@ func (db *DB) Insert(ctx context.Context, values ...any) error {
 	})
 }
 
+// Upsert calls Upsert on a new writable Tx.
+func (db *DB) Upsert(ctx context.Context, values ...any) ([]bool, error) {
+	var inserted []bool
+	err := db.Write(ctx, func(tx *Tx) error {
+		var err error
+		inserted, err = tx.Upsert(values...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+// InsertOrIgnore calls InsertOrIgnore on a new writable Tx.
+func (db *DB) InsertOrIgnore(ctx context.Context, uniqueIndex string, values ...any) ([]bool, error) {
+	var inserted []bool
+	err := db.Write(ctx, func(tx *Tx) error {
+		var err error
+		inserted, err = tx.InsertOrIgnore(uniqueIndex, values...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
 // Update calls Update on a new writable Tx.
 func (db *DB) Update(ctx context.Context, values ...any) error {
 	return db.Write(ctx, func(tx *Tx) error {
@@ -567,7 +685,10 @@ func (tv typeVersion) keyValue(tx *Tx, rv reflect.Value, insert bool, rb *bolt.B
 	f := tv.Fields[0]
 	krv := rv.FieldByIndex(f.structField.Index)
 	var seq bool
-	if krv.IsZero() {
+	if krv.IsZero() && insert && tv.Autogen != "" {
+		tv.generatePK(krv, tx.db.now())
+		seq = true
+	} else if krv.IsZero() {
 		if !insert {
 			return nil, reflect.Value{}, seq, fmt.Errorf("%w: primary key can not be zero value", ErrParam)
 		}
@@ -4,12 +4,15 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/gob"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
 	mathrand "math/rand"
 	"os"
 	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -684,6 +687,2265 @@ func TestReference(t *testing.T) {
 	tcheck(t, err, "db update")
 }
 
+func TestReferenceCascade(t *testing.T) {
+	type Mailbox struct {
+		ID   int
+		Name string
+	}
+	type Message struct {
+		ID        int
+		MailboxID int `bstore:"nonzero,ref Mailbox cascade"`
+	}
+	type Part struct {
+		ID        int
+		MessageID int `bstore:"nonzero,ref Message cascade"`
+		ParentID  int `bstore:"ref Part cascade"`
+	}
+	type Rule struct {
+		ID        int
+		MailboxID int `bstore:"ref Mailbox setzero"`
+		Name      string
+	}
+	type Node struct {
+		ID       int
+		ParentID int `bstore:"ref Node setzero"`
+		Name     string
+	}
+
+	type Bad struct {
+		ID        int
+		MailboxID int `bstore:"nonzero,ref Mailbox setzero"`
+	}
+	type Bad2 struct {
+		ID        int
+		MailboxID int `bstore:"ref Mailbox bogus"`
+	}
+
+	const path = "testdata/tmp.referencecascade.db"
+	os.Remove(path)
+
+	_, err := topen(t, path, nil, Bad{}, Mailbox{})
+	tneed(t, err, ErrType, "nonzero with setzero")
+	_, err = topen(t, path, nil, Bad2{}, Mailbox{})
+	tneed(t, err, ErrType, "unknown ref action")
+
+	db, err := topen(t, path, nil, Mailbox{}, Message{}, Part{}, Rule{}, Node{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	mb := Mailbox{Name: "inbox"}
+	mb2 := Mailbox{Name: "other"}
+	err = db.Insert(ctxbg, &mb, &mb2)
+	tcheck(t, err, "insert mailboxes")
+	m0 := Message{MailboxID: mb.ID}
+	m1 := Message{MailboxID: mb.ID}
+	m2 := Message{MailboxID: mb2.ID}
+	err = db.Insert(ctxbg, &m0, &m1, &m2)
+	tcheck(t, err, "insert messages")
+	p0 := Part{MessageID: m0.ID}
+	err = db.Insert(ctxbg, &p0)
+	tcheck(t, err, "insert part")
+	// Parts referencing each other through a cycle.
+	p1 := Part{MessageID: m1.ID}
+	err = db.Insert(ctxbg, &p1)
+	tcheck(t, err, "insert part")
+	p2 := Part{MessageID: m1.ID, ParentID: p1.ID}
+	err = db.Insert(ctxbg, &p2)
+	tcheck(t, err, "insert part")
+	p1.ParentID = p2.ID
+	err = db.Update(ctxbg, &p1)
+	tcheck(t, err, "update part")
+	p3 := Part{MessageID: m2.ID}
+	err = db.Insert(ctxbg, &p3)
+	tcheck(t, err, "insert part")
+	r0 := Rule{MailboxID: mb.ID, Name: "r0"}
+	r1 := Rule{MailboxID: mb2.ID, Name: "r1"}
+	err = db.Insert(ctxbg, &r0, &r1)
+	tcheck(t, err, "insert rules")
+
+	err = db.Delete(ctxbg, &mb)
+	tcheck(t, err, "delete mailbox")
+
+	msgs, err := QueryDB[Message](ctxbg, db).List()
+	tcompare(t, err, msgs, []Message{m2}, "remaining messages")
+	parts, err := QueryDB[Part](ctxbg, db).List()
+	tcompare(t, err, parts, []Part{p3}, "remaining parts")
+	rules, err := QueryDB[Rule](ctxbg, db).List()
+	tcompare(t, err, rules, []Rule{{r0.ID, 0, "r0"}, r1}, "rules after delete")
+
+	// Deleting with a query also cascades.
+	n, err := QueryDB[Mailbox](ctxbg, db).Delete()
+	tcompare(t, err, n, 1, "delete mailboxes")
+	n, err = QueryDB[Part](ctxbg, db).Count()
+	tcompare(t, err, n, 0, "parts after delete")
+
+	// Self-referencing records, deleted through a query.
+	mb3 := Mailbox{Name: "x"}
+	err = db.Insert(ctxbg, &mb3)
+	tcheck(t, err, "insert mailbox")
+	m3 := Message{MailboxID: mb3.ID}
+	err = db.Insert(ctxbg, &m3)
+	tcheck(t, err, "insert message")
+	var prev int
+	for range 10 {
+		p := Part{MessageID: m3.ID, ParentID: prev}
+		err = db.Insert(ctxbg, &p)
+		tcheck(t, err, "insert part")
+		prev = p.ID
+	}
+	n, err = QueryDB[Part](ctxbg, db).Delete()
+	tcompare(t, err, n, 1, "delete parts through cascade")
+	n, err = QueryDB[Part](ctxbg, db).Count()
+	tcompare(t, err, n, 0, "parts after delete")
+
+	// Sorted queries gather records before deleting, cascade and setzero actions
+	// for earlier records change later records. Index keys must be removed for the
+	// stored values.
+	mb4 := Mailbox{Name: "y"}
+	err = db.Insert(ctxbg, &mb4)
+	tcheck(t, err, "insert mailbox")
+	m4 := Message{MailboxID: mb4.ID}
+	err = db.Insert(ctxbg, &m4)
+	tcheck(t, err, "insert message")
+	p4 := Part{MessageID: m4.ID}
+	err = db.Insert(ctxbg, &p4)
+	tcheck(t, err, "insert part")
+	p5 := Part{MessageID: m4.ID, ParentID: p4.ID}
+	err = db.Insert(ctxbg, &p5)
+	tcheck(t, err, "insert part")
+	n, err = QueryDB[Part](ctxbg, db).SortAsc("ParentID").Delete()
+	tcompare(t, err, n, 1, "delete sorted parts through cascade")
+	n0 := Node{Name: "a"}
+	err = db.Insert(ctxbg, &n0)
+	tcheck(t, err, "insert node")
+	n1 := Node{ParentID: n0.ID, Name: "b"}
+	err = db.Insert(ctxbg, &n1)
+	tcheck(t, err, "insert node")
+	n, err = QueryDB[Node](ctxbg, db).SortAsc("Name").Delete()
+	tcompare(t, err, n, 2, "delete sorted nodes with setzero")
+	n, err = QueryDB[Mailbox](ctxbg, db).Delete()
+	tcompare(t, err, n, 2, "delete mailboxes")
+	n, err = QueryDB[Rule](ctxbg, db).Delete()
+	tcompare(t, err, n, 2, "delete rules")
+
+	err = db.bdb.View(func(btx *bolt.Tx) error {
+		for _, name := range []string{"Mailbox", "Message", "Part", "Rule", "Node"} {
+			b := btx.Bucket([]byte(name))
+			err := b.ForEachBucket(func(k []byte) error {
+				if !strings.HasPrefix(string(k), "index.") {
+					return nil
+				}
+				ik, _ := b.Bucket(k).Cursor().First()
+				if ik != nil {
+					t.Fatalf("%s bucket %s not empty after deleting all records: %x", name, k, ik)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	tcheck(t, err, "checking index buckets")
+}
+
+func TestUpsert(t *testing.T) {
+	type User struct {
+		ID    int
+		Email string `bstore:"unique"`
+		Name  string `bstore:"default anon"`
+	}
+
+	const path = "testdata/tmp.upsert.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, User{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	a := User{Email: "a@example.org"}
+	b := User{ID: 10, Email: "b@example.org", Name: "b"}
+	inserted, err := db.Upsert(ctxbg, &a, &b)
+	tcompare(t, err, inserted, []bool{true, true}, "upsert new")
+	tcompare(t, err, a, User{1, "a@example.org", "anon"}, "upserted with default")
+
+	b.Name = "bee"
+	c := User{Email: "c@example.org"}
+	inserted, err = db.Upsert(ctxbg, &b, &c)
+	tcompare(t, err, inserted, []bool{false, true}, "upsert existing")
+	x := User{ID: b.ID}
+	err = db.Get(ctxbg, &x)
+	tcompare(t, err, x, b, "get updated")
+
+	_, err = db.Upsert(ctxbg, &User{ID: 20, Email: "a@example.org"})
+	tneed(t, err, ErrUnique, "upsert with unique conflict")
+
+	_, err = db.Upsert(ctxbg, User{})
+	tneed(t, err, ErrParam, "upsert non-pointer")
+
+	d := User{Email: "b@example.org", Name: "dup"}
+	e := User{Email: "e@example.org"}
+	f := User{ID: a.ID, Email: "f@example.org"}
+	inserted, err = db.InsertOrIgnore(ctxbg, "Email", &d, &e, &f)
+	tcompare(t, err, inserted, []bool{false, true, false}, "insert or ignore")
+	tcompare(t, nil, f, User{ID: a.ID, Email: "f@example.org"}, "skipped value without defaults")
+	tcompare(t, nil, e.Name, "anon", "inserted value with default")
+	n, err := QueryDB[User](ctxbg, db).Count()
+	tcompare(t, err, n, 4, "count")
+
+	inserted, err = db.InsertOrIgnore(ctxbg, "", &f)
+	tcompare(t, err, inserted, []bool{false}, "insert or ignore on pk")
+
+	_, err = db.InsertOrIgnore(ctxbg, "", &d)
+	tneed(t, err, ErrUnique, "insert or ignore without unique index")
+
+	_, err = db.InsertOrIgnore(ctxbg, "Name", &d)
+	tneed(t, err, ErrParam, "insert or ignore with unknown unique index")
+}
+
+func TestTypedAccessors(t *testing.T) {
+	type User struct {
+		ID   int64
+		Name string
+	}
+	type Other struct {
+		ID string
+	}
+
+	const path = "testdata/tmp.typedaccessors.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, User{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	u := User{Name: "a"}
+	err = db.Insert(ctxbg, &u)
+	tcheck(t, err, "insert")
+
+	err = db.Write(ctxbg, func(tx *Tx) error {
+		x, err := Get[User](tx, u.ID)
+		tcompare(t, err, x, u, "get")
+
+		_, err = Get[User](tx, u.ID+1)
+		tneed(t, err, ErrAbsent, "get absent")
+
+		_, err = Get[User](tx, int(u.ID))
+		tneed(t, err, ErrParam, "get with wrong id type")
+
+		_, err = Get[User](tx, nil)
+		tneed(t, err, ErrParam, "get with nil id")
+
+		_, err = Get[User](tx, int64(0))
+		tneed(t, err, ErrParam, "get with zero id")
+
+		_, err = Get[Other](tx, "x")
+		tneed(t, err, ErrType, "get unregistered type")
+
+		_, err = Get[*User](tx, u.ID)
+		tneed(t, err, ErrType, "get pointer type")
+
+		exists, err := Exists[User](tx, u.ID)
+		tcompare(t, err, exists, true, "exists")
+
+		exists, err = Exists[User](tx, u.ID+1)
+		tcompare(t, err, exists, false, "exists absent")
+
+		err = DeleteID[User](tx, u.ID+1)
+		tneed(t, err, ErrAbsent, "delete absent")
+
+		err = DeleteID[User](tx, u.ID)
+		tcheck(t, err, "delete")
+
+		exists, err = Exists[User](tx, u.ID)
+		tcompare(t, err, exists, false, "exists after delete")
+		return nil
+	})
+	tcheck(t, err, "write")
+}
+
+func TestConstraintError(t *testing.T) {
+	type Group struct {
+		ID   int
+		Name string `bstore:"unique"`
+	}
+	type Address struct {
+		Street string `bstore:"nonzero"`
+	}
+	type User struct {
+		ID      int
+		Name    string `bstore:"nonzero"`
+		First   string `bstore:"unique First+Last"`
+		Last    string
+		GroupID int `bstore:"ref Group"`
+		Address Address
+	}
+
+	const path = "testdata/tmp.constrainterror.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, Group{}, User{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	need := func(err, expErr error, exp ConstraintError, msg string) {
+		t.Helper()
+		tneed(t, err, expErr, msg)
+		var cerr *ConstraintError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("%s: got %v, expected ConstraintError", msg, err)
+		}
+		tcompare(t, nil, *cerr, exp, msg)
+	}
+
+	g := Group{Name: "g"}
+	err = db.Insert(ctxbg, &g)
+	tcheck(t, err, "insert group")
+	u := User{Name: "u", First: "a", Last: "b", GroupID: g.ID, Address: Address{"x"}}
+	err = db.Insert(ctxbg, &u)
+	tcheck(t, err, "insert user")
+
+	err = db.Insert(ctxbg, &Group{Name: "g"})
+	need(err, ErrUnique, ConstraintError{ErrUnique, "Group", "Name", "Name", "g", ""}, "unique")
+
+	err = db.Insert(ctxbg, &Group{ID: g.ID, Name: "other"})
+	need(err, ErrUnique, ConstraintError{ErrUnique, "Group", "ID", "", g.ID, ""}, "primary key")
+
+	err = db.Insert(ctxbg, &User{Name: "u", First: "a", Last: "b", Address: Address{"x"}})
+	need(err, ErrUnique, ConstraintError{ErrUnique, "User", "First+Last", "First+Last", []any{"a", "b"}, ""}, "unique multiple fields")
+
+	err = db.Insert(ctxbg, &User{Address: Address{"x"}})
+	need(err, ErrZero, ConstraintError{ErrZero, "User", "Name", "", nil, ""}, "nonzero")
+
+	err = db.Insert(ctxbg, &User{Name: "u"})
+	need(err, ErrZero, ConstraintError{ErrZero, "User", "Address.Street", "", nil, ""}, "nonzero nested")
+
+	err = db.Insert(ctxbg, &User{Name: "u", GroupID: 10, Address: Address{"x"}})
+	need(err, ErrReference, ConstraintError{ErrReference, "User", "GroupID", "", 10, "Group"}, "reference")
+
+	err = db.Delete(ctxbg, &g)
+	need(err, ErrReference, ConstraintError{ErrReference, "User", "GroupID", "GroupID:Group", g.ID, "Group"}, "delete referenced")
+
+	err = db.Write(ctxbg, func(tx *Tx) error {
+		_, err := BulkLoad(tx, slices.Values([]Group{{Name: "g"}}))
+		need(err, ErrUnique, ConstraintError{ErrUnique, "Group", "Name", "Name", "g", ""}, "bulk load unique")
+		return nil
+	})
+	tneed(t, err, ErrTxBotched, "write")
+
+	cerr := &ConstraintError{ErrReference, "User", "GroupID", "", 10, "Group"}
+	tcompare(t, nil, cerr.Error(), `referential inconsistency: type "User", field "GroupID", value 10, referenced type "Group"`, "error string")
+}
+
+type Validated struct {
+	ID    int
+	Name  string `bstore:"default x"`
+	Count int
+}
+
+var errNegative = errors.New("negative count")
+
+func (v *Validated) BstoreValidate() error {
+	if v.Name == "" {
+		return errors.New("default not applied before validation")
+	}
+	if v.Count < 0 {
+		return errNegative
+	}
+	return nil
+}
+
+type Validated2 struct {
+	ID    int `bstore:"typename Validated"`
+	Name  string
+	Count int
+	Extra string
+}
+
+var errLarge = errors.New("count too large")
+
+func (v *Validated2) BstoreValidate() error {
+	if v.Count > 5 {
+		return errLarge
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	const path = "testdata/tmp.validate.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, Validated{})
+	tcheck(t, err, "open")
+
+	v := Validated{Count: 10}
+	err = db.Insert(ctxbg, &v)
+	tcompare(t, err, v, Validated{1, "x", 10}, "insert with default")
+
+	err = db.Write(ctxbg, func(tx *Tx) error {
+		err := tx.Insert(&Validated{Count: -1})
+		tneed(t, err, errNegative, "insert invalid")
+
+		// Tx is not botched.
+		err = tx.Insert(&Validated{})
+		tcheck(t, err, "insert after failed validation")
+
+		err = tx.Update(&Validated{ID: v.ID, Name: "x", Count: -1})
+		tneed(t, err, errNegative, "update invalid")
+
+		_, err = tx.Upsert(&Validated{Count: -1})
+		tneed(t, err, errNegative, "upsert invalid")
+
+		_, err = QueryTx[Validated](tx).UpdateField("Count", -1)
+		tneed(t, err, errNegative, "query update invalid")
+
+		_, err = BulkLoad(tx, slices.Values([]Validated{{Count: -1}}))
+		tneed(t, err, errNegative, "bulk load invalid")
+		return nil
+	})
+	tneed(t, err, ErrTxBotched, "write after failed bulk load")
+
+	n, err := QueryDB[Validated](ctxbg, db).FilterLess("Count", 0).Count()
+	tcompare(t, err, n, 0, "no invalid records")
+	tclose(t, db)
+
+	// Existing records are only validated during schema changes when requested.
+	db, err = topen(t, path, &Options{RegisterValidate: true}, Validated2{})
+	tneed(t, err, errLarge, "open with validation of existing records")
+	db, err = topen(t, path, nil, Validated2{})
+	tcheck(t, err, "open without validation of existing records")
+	tclose(t, db)
+}
+
+func TestHooks(t *testing.T) {
+	type Group struct {
+		ID      int
+		Members int `bstore:"index"`
+	}
+	type Member struct {
+		ID      int
+		GroupID int `bstore:"ref Group cascade"`
+		Name    string
+	}
+	type Audit struct {
+		ID  int
+		Msg string
+	}
+
+	const path = "testdata/tmp.hooks.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, Group{}, Member{}, Audit{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	audit := func(tx *Tx, format string, args ...any) error {
+		return tx.Insert(&Audit{Msg: fmt.Sprintf(format, args...)})
+	}
+	count := func(tx *Tx, groupID, delta int) error {
+		g, err := Get[Group](tx, groupID)
+		if err != nil {
+			return err
+		}
+		g.Members += delta
+		return tx.Update(&g)
+	}
+	errHook := errors.New("hook failed")
+	err = SetHooks(db, Hooks[Member]{
+		BeforeInsert: func(tx *Tx, v *Member) error {
+			if v.ID == 0 {
+				return fmt.Errorf("id not yet assigned")
+			}
+			if v.Name == "fail" {
+				return errHook
+			}
+			v.Name = strings.ToUpper(v.Name)
+			return nil
+		},
+		AfterInsert: func(tx *Tx, v *Member) error {
+			if err := count(tx, v.GroupID, 1); err != nil {
+				return err
+			}
+			return audit(tx, "insert %d %s", v.ID, v.Name)
+		},
+		BeforeUpdate: func(tx *Tx, old, new *Member) error {
+			new.Name = strings.ToUpper(new.Name)
+			return nil
+		},
+		AfterUpdate: func(tx *Tx, old, new *Member) error {
+			return audit(tx, "update %d %s %s", new.ID, old.Name, new.Name)
+		},
+		AfterDelete: func(tx *Tx, old *Member) error {
+			if err := count(tx, old.GroupID, -1); err != nil {
+				return err
+			}
+			return audit(tx, "delete %d", old.ID)
+		},
+	})
+	tcheck(t, err, "set hooks")
+
+	err = SetHooks(db, Hooks[Audit]{
+		BeforeDelete: func(tx *Tx, old *Audit) error {
+			return errHook
+		},
+	})
+	tcheck(t, err, "set hooks")
+
+	g := Group{}
+	err = db.Insert(ctxbg, &g)
+	tcheck(t, err, "insert group")
+
+	m0 := Member{GroupID: g.ID, Name: "a"}
+	m1 := Member{GroupID: g.ID, Name: "b"}
+	err = db.Insert(ctxbg, &m0, &m1)
+	tcompare(t, err, m0, Member{1, g.ID, "A"}, "insert with hook modification")
+
+	m0.Name = "c"
+	err = db.Update(ctxbg, &m0)
+	tcompare(t, err, m0.Name, "C", "update with hook modification")
+
+	var gathered []Member
+	_, err = QueryDB[Member](ctxbg, db).FilterID(m1.ID).Gather(&gathered).UpdateField("Name", "d")
+	tcompare(t, err, gathered, []Member{{m1.ID, g.ID, "D"}}, "query update")
+
+	// Updates without changes don't call hooks.
+	err = db.Update(ctxbg, &m0)
+	tcheck(t, err, "update without change")
+
+	n, err := QueryDB[Group](ctxbg, db).FilterEqual("Members", 2).Count()
+	tcompare(t, err, n, 1, "member count after inserts")
+
+	_, err = QueryDB[Member](ctxbg, db).FilterID(m0.ID).Delete()
+	tcheck(t, err, "query delete")
+
+	// Cascading delete calls hooks of deleted member, which updates the group being
+	// deleted.
+	err = db.Delete(ctxbg, &g)
+	tcheck(t, err, "delete group")
+
+	msgs, err := QueryDB[Audit](ctxbg, db).List()
+	tcheck(t, err, "list audit")
+	var l []string
+	for _, a := range msgs {
+		l = append(l, a.Msg)
+	}
+	tcompare(t, nil, l, []string{"insert 1 A", "insert 2 B", "update 1 A C", "update 2 B D", "delete 1", "delete 2"}, "audit messages")
+
+	n, err = QueryDB[Group](ctxbg, db).Count()
+	tcompare(t, err, n, 0, "groups after delete")
+
+	err = db.Write(ctxbg, func(tx *Tx) error {
+		err := tx.Insert(&Member{Name: "fail"})
+		tneed(t, err, errHook, "hook error")
+		err = tx.Insert(&Audit{})
+		tneed(t, err, ErrTxBotched, "tx botched after hook error")
+		return nil
+	})
+	tneed(t, err, ErrTxBotched, "write")
+
+	_, err = QueryDB[Audit](ctxbg, db).Delete()
+	tneed(t, err, errHook, "before delete hook error")
+	n, err = QueryDB[Audit](ctxbg, db).Count()
+	tcompare(t, err, n, 6, "audit records after failed delete")
+
+	// Removing hooks.
+	err = SetHooks(db, Hooks[Audit]{})
+	tcheck(t, err, "remove hooks")
+	_, err = QueryDB[Audit](ctxbg, db).Delete()
+	tcheck(t, err, "delete without hooks")
+
+	// Before hooks cannot change the primary key.
+	err = SetHooks(db, Hooks[Audit]{
+		BeforeInsert: func(tx *Tx, v *Audit) error {
+			v.ID++
+			return nil
+		},
+		BeforeUpdate: func(tx *Tx, old, new *Audit) error {
+			new.ID++
+			return nil
+		},
+	})
+	tcheck(t, err, "set hooks")
+	err = db.Insert(ctxbg, &Audit{Msg: "x"})
+	tneed(t, err, ErrParam, "insert with hook changing primary key")
+
+	// BulkLoad does not call hooks.
+	err = db.Write(ctxbg, func(tx *Tx) error {
+		n, err := BulkLoad(tx, slices.Values([]Audit{{Msg: "bulk"}}))
+		tcompare(t, err, n, 1, "bulk load")
+		return err
+	})
+	tcheck(t, err, "write")
+	a, err := QueryDB[Audit](ctxbg, db).Get()
+	tcompare(t, err, a, Audit{7, "bulk"}, "bulk loaded without hooks")
+
+	err = db.Update(ctxbg, &Audit{ID: a.ID, Msg: "changed"})
+	tneed(t, err, ErrParam, "update with hook changing primary key")
+
+	err = SetHooks(db, Hooks[Audit]{})
+	tcheck(t, err, "remove hooks")
+
+	// Hooks of a cascade target can delete records selected by a query delete.
+	g1 := Group{}
+	g2 := Group{}
+	err = db.Insert(ctxbg, &g1, &g2)
+	tcheck(t, err, "insert groups")
+	err = db.Insert(ctxbg, &Member{GroupID: g1.ID})
+	tcheck(t, err, "insert member")
+	err = SetHooks(db, Hooks[Member]{
+		AfterDelete: func(tx *Tx, old *Member) error {
+			return tx.Delete(&Group{ID: g2.ID})
+		},
+	})
+	tcheck(t, err, "set hooks")
+	n, err = QueryDB[Group](ctxbg, db).Delete()
+	tcompare(t, err, n, 1, "query delete with hooks deleting selected record")
+
+	// Query updates use the stored record, hooks for an earlier record may have
+	// changed it.
+	g3 := Group{}
+	g4 := Group{}
+	err = db.Insert(ctxbg, &g3, &g4)
+	tcheck(t, err, "insert groups")
+	err = SetHooks(db, Hooks[Member]{})
+	tcheck(t, err, "remove hooks")
+	ma := Member{GroupID: g3.ID, Name: "a"}
+	mb := Member{GroupID: g3.ID, Name: "b"}
+	err = db.Insert(ctxbg, &ma, &mb)
+	tcheck(t, err, "insert members")
+	err = SetHooks(db, Hooks[Member]{
+		AfterUpdate: func(tx *Tx, old, new *Member) error {
+			if new.ID != ma.ID {
+				return nil
+			}
+			return tx.Update(&Member{mb.ID, g4.ID, old.Name + new.Name})
+		},
+	})
+	tcheck(t, err, "set hooks")
+	n, err = QueryDB[Member](ctxbg, db).SortAsc("Name").UpdateFn(func(v *Member) error {
+		v.Name += "x"
+		return nil
+	})
+	tcompare(t, err, n, 2, "query update with hook changing selected record")
+	mb, err = QueryDB[Member](ctxbg, db).FilterID(mb.ID).Get()
+	tcompare(t, err, mb, Member{mb.ID, g4.ID, "aaxx"}, "record changed by hook and update")
+	_, err = QueryDB[Member](ctxbg, db).SortAsc("Name").UpdateField("GroupID", g4.ID)
+	tcheck(t, err, "query update field")
+	mb, err = QueryDB[Member](ctxbg, db).FilterID(mb.ID).Get()
+	tcompare(t, err, mb, Member{mb.ID, g4.ID, "axax"}, "record changed by hook and update field")
+
+	type Other struct {
+		ID int
+	}
+	err = SetHooks(db, Hooks[Other]{})
+	tneed(t, err, ErrType, "hooks for unregistered type")
+}
+
+func TestCheck(t *testing.T) {
+	type Details struct {
+		Tags []string `bstore:"maxlen 2"`
+	}
+	type Ticket struct {
+		ID       int
+		Status   string  `bstore:"enum open closed pending,default open"`
+		Priority int     `bstore:"enum 1 2 3,default 2"`
+		Percent  float64 `bstore:"min 0,max 100"`
+		Count    *uint8  `bstore:"min 1"`
+		Title    string  `bstore:"maxlen 5"`
+		Code     string  `bstore:"pattern ^[a-z]*$"`
+		Details  Details
+	}
+
+	const path = "testdata/tmp.check.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, Ticket{})
+	tcheck(t, err, "open")
+
+	tk := Ticket{Title: "héllo", Code: "abc", Count: ptr(uint8(1))}
+	err = db.Insert(ctxbg, &tk)
+	tcheck(t, err, "insert")
+
+	bad := func(tk Ticket, field string, value any) {
+		t.Helper()
+		err := db.Insert(ctxbg, &tk)
+		tneed(t, err, ErrCheck, "insert with bad "+field)
+		var cerr *ConstraintError
+		if !errors.As(err, &cerr) {
+			t.Fatalf("got %v, expected ConstraintError", err)
+		}
+		tcompare(t, nil, *cerr, ConstraintError{ErrCheck, "Ticket", field, "", value, ""}, "constraint error")
+	}
+	bad(Ticket{Status: "bogus"}, "Status", "bogus")
+	bad(Ticket{Priority: 4}, "Priority", 4)
+	bad(Ticket{Percent: -1}, "Percent", -1.0)
+	bad(Ticket{Percent: 100.5}, "Percent", 100.5)
+	bad(Ticket{Count: ptr(uint8(0))}, "Count", uint8(0))
+	bad(Ticket{Title: "toolong"}, "Title", "toolong")
+	bad(Ticket{Code: "ABC"}, "Code", "ABC")
+	bad(Ticket{Details: Details{[]string{"a", "b", "c"}}}, "Details.Tags", []string{"a", "b", "c"})
+
+	tk.Status = "closed"
+	err = db.Update(ctxbg, &tk)
+	tcheck(t, err, "update")
+	_, err = QueryDB[Ticket](ctxbg, db).UpdateField("Status", "bogus")
+	tneed(t, err, ErrCheck, "query update")
+	tclose(t, db)
+
+	// Adding a check that existing records violate fails.
+	type Ticket2 struct {
+		ID     int    `bstore:"typename Ticket"`
+		Status string `bstore:"enum open"`
+	}
+	_, err = topen(t, path, nil, Ticket2{})
+	tneed(t, err, ErrCheck, "open with check violated by existing record")
+	type Ticket3 struct {
+		ID     int    `bstore:"typename Ticket"`
+		Status string `bstore:"enum open closed"`
+	}
+	db, err = topen(t, path, nil, Ticket3{})
+	tcheck(t, err, "open with changed check")
+	tcompare(t, nil, db.typeNames["Ticket"].Current.Fields[1].Check.Enum, []string{"open", "closed"}, "check in type version")
+	tclose(t, db)
+
+	type BadEnum struct {
+		ID int
+		F  float64 `bstore:"enum 1 2"`
+	}
+	type BadMin struct {
+		ID int
+		F  string `bstore:"min 1"`
+	}
+	type BadMaxlen struct {
+		ID int
+		F  int `bstore:"maxlen 1"`
+	}
+	type BadPattern struct {
+		ID int
+		F  string `bstore:"pattern ("`
+	}
+	type BadPK struct {
+		ID int `bstore:"min 1"`
+	}
+	for _, v := range []any{BadEnum{}, BadMin{}, BadMaxlen{}, BadPattern{}, BadPK{}} {
+		os.Remove(path)
+		_, err = topen(t, path, nil, v)
+		tneed(t, err, ErrType, fmt.Sprintf("bad check tag on %T", v))
+	}
+}
+
+func TestAutoTime(t *testing.T) {
+	type Item struct {
+		ID      int
+		Name    string
+		Created time.Time  `bstore:"autocreate"`
+		Updated *time.Time `bstore:"autoupdate"`
+		Seen    time.Time  `bstore:"default now"`
+	}
+
+	const path = "testdata/tmp.autotime.db"
+	os.Remove(path)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+	db, err := topen(t, path, &Options{Now: now}, Item{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	minute := func(m int) time.Time {
+		return time.Date(2024, 1, 1, 0, m, 0, 0, time.UTC)
+	}
+
+	it := Item{Name: "a"}
+	err = db.Insert(ctxbg, &it)
+	tcheck(t, err, "insert")
+	tcompare(t, err, []time.Time{it.Created, *it.Updated, it.Seen}, []time.Time{minute(2), minute(2), minute(1)}, "times after insert")
+
+	// Explicit values are kept on insert.
+	it2 := Item{Created: minute(30), Updated: ptr(minute(30))}
+	err = db.Insert(ctxbg, &it2)
+	tcompare(t, err, []time.Time{it2.Created, *it2.Updated}, []time.Time{minute(30), minute(30)}, "explicit times on insert")
+
+	// Unchanged records are not updated.
+	err = db.Update(ctxbg, &it)
+	tcompare(t, err, *it.Updated, minute(2), "update without changes")
+
+	// Zero autocreate field keeps the stored value.
+	it.Name = "b"
+	it.Created = time.Time{}
+	err = db.Update(ctxbg, &it)
+	tcompare(t, err, []time.Time{it.Created, *it.Updated}, []time.Time{minute(2), minute(4)}, "times after update")
+
+	_, err = QueryDB[Item](ctxbg, db).FilterID(it.ID).UpdateField("Name", "c")
+	tcheck(t, err, "query update")
+	x, err := QueryDB[Item](ctxbg, db).FilterID(it.ID).Get()
+	tcompare(t, err, []time.Time{x.Created, *x.Updated}, []time.Time{minute(2), minute(5)}, "times after query update")
+
+	type Bad struct {
+		ID int
+		S  string `bstore:"autocreate"`
+	}
+	type BadBoth struct {
+		ID int
+		T  time.Time `bstore:"autocreate,autoupdate"`
+	}
+	type Nested struct {
+		T time.Time `bstore:"autoupdate"`
+	}
+	type BadNested struct {
+		ID int
+		N  Nested
+	}
+	for _, v := range []any{Bad{}, BadBoth{}, BadNested{}} {
+		err := db.Register(ctxbg, v)
+		tneed(t, err, ErrType, fmt.Sprintf("register %T", v))
+	}
+}
+
+func TestVersion(t *testing.T) {
+	type Doc struct {
+		ID      int
+		Text    string
+		Version uint8 `bstore:"version"`
+	}
+
+	const path = "testdata/tmp.version.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, Doc{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	d := Doc{Text: "a"}
+	err = db.Insert(ctxbg, &d)
+	tcompare(t, err, d.Version, uint8(1), "version after insert")
+
+	// Two concurrent editors.
+	d0 := d
+	d1 := d
+
+	d0.Text = "b"
+	err = db.Update(ctxbg, &d0)
+	tcompare(t, err, d0.Version, uint8(2), "version after update")
+
+	d1.Text = "c"
+	err = db.Update(ctxbg, &d1)
+	tneed(t, err, ErrVersion, "update with stale version")
+	var cerr *ConstraintError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("got %v, expected ConstraintError", err)
+	}
+	tcompare(t, nil, *cerr, ConstraintError{ErrVersion, "Doc", "Version", "", uint8(2), ""}, "constraint error")
+
+	// Unchanged record does not change version.
+	err = db.Update(ctxbg, &d0)
+	tcompare(t, err, d0.Version, uint8(2), "version after update without changes")
+
+	_, err = QueryDB[Doc](ctxbg, db).UpdateField("Text", "d")
+	tcheck(t, err, "query update")
+	err = db.Get(ctxbg, &d0)
+	tcompare(t, err, d0, Doc{d.ID, "d", 3}, "after query update")
+
+	_, err = QueryDB[Doc](ctxbg, db).UpdateField("Version", uint8(10))
+	tneed(t, err, ErrVersion, "query update of version")
+
+	err = db.Write(ctxbg, func(tx *Tx) error {
+		d := Doc{Text: "y", Version: 255}
+		err := tx.Insert(&d)
+		tcheck(t, err, "insert with max version")
+		d.Text = "z"
+		err = tx.Update(&d)
+		tneed(t, err, ErrSeq, "version overflow")
+		return nil
+	})
+	tcheck(t, err, "write")
+
+	// Failed writes leave the automatically set fields unchanged, the value can be
+	// corrected and written again.
+	type Owner struct {
+		ID int
+	}
+	type Item struct {
+		ID      int
+		OwnerID int `bstore:"ref Owner"`
+		Text    string
+		Version int       `bstore:"version"`
+		Modseq  int       `bstore:"modseq"`
+		Updated time.Time `bstore:"autoupdate"`
+	}
+	err = db.Register(ctxbg, Owner{}, Item{})
+	tcheck(t, err, "register")
+	o := Owner{}
+	err = db.Insert(ctxbg, &o)
+	tcheck(t, err, "insert owner")
+
+	it := Item{OwnerID: 100}
+	err = db.Insert(ctxbg, &it)
+	tneed(t, err, ErrReference, "insert with absent reference")
+	tcompare(t, nil, it, Item{OwnerID: 100}, "item after failed insert")
+	it.OwnerID = o.ID
+	err = db.Insert(ctxbg, &it)
+	tcompare(t, err, it.Version, 1, "version after insert retry")
+
+	exp := it
+	exp.OwnerID = 100
+	exp.Text = "b"
+	it.OwnerID = 100
+	it.Text = "b"
+	err = db.Update(ctxbg, &it)
+	tneed(t, err, ErrReference, "update with absent reference")
+	tcompare(t, nil, it, exp, "item after failed update")
+	it.OwnerID = o.ID
+	err = db.Update(ctxbg, &it)
+	tcompare(t, err, it.Version, 2, "version after update retry")
+
+	type BadType struct {
+		ID int
+		V  string `bstore:"version"`
+	}
+	type BadMultiple struct {
+		ID int
+		A  int `bstore:"version"`
+		B  int `bstore:"version"`
+	}
+	for _, v := range []any{BadType{}, BadMultiple{}} {
+		err := db.Register(ctxbg, v)
+		tneed(t, err, ErrType, fmt.Sprintf("register %T", v))
+	}
+}
+
+func TestSeq(t *testing.T) {
+	type Msg struct {
+		ID     int
+		UID    uint32 `bstore:"seq"`
+		ModSeq int64  `bstore:"modseq"`
+		Text   string
+	}
+
+	const path = "testdata/tmp.seq.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, Msg{})
+	tcheck(t, err, "open")
+
+	m0 := Msg{Text: "a"}
+	err = db.Insert(ctxbg, &m0)
+	tcompare(t, err, m0, Msg{1, 1, 1, "a"}, "insert")
+
+	m1 := Msg{UID: 10, Text: "b"}
+	err = db.Insert(ctxbg, &m1)
+	tcompare(t, err, m1, Msg{2, 10, 2, "b"}, "insert with explicit seq")
+
+	m2 := Msg{Text: "c"}
+	err = db.Insert(ctxbg, &m2)
+	tcompare(t, err, m2, Msg{3, 11, 3, "c"}, "insert after explicit seq")
+
+	m0.Text = "aa"
+	err = db.Update(ctxbg, &m0)
+	tcompare(t, err, m0, Msg{1, 1, 4, "aa"}, "update")
+
+	err = db.Update(ctxbg, &m0)
+	tcompare(t, err, m0.ModSeq, int64(4), "update without changes")
+
+	_, err = QueryDB[Msg](ctxbg, db).FilterID(m1.ID).UpdateField("Text", "bb")
+	tcheck(t, err, "query update")
+	err = db.Get(ctxbg, &m1)
+	tcompare(t, err, m1, Msg{2, 10, 5, "bb"}, "after query update")
+
+	// Changes are rolled back with the transaction.
+	err = db.Write(ctxbg, func(tx *Tx) error {
+		m := Msg{Text: "x"}
+		if err := tx.Insert(&m); err != nil {
+			return err
+		}
+		return errors.New("abort")
+	})
+	if err == nil || err.Error() != "abort" {
+		t.Fatalf("got %v, expected abort", err)
+	}
+	m3 := Msg{Text: "d"}
+	err = db.Insert(ctxbg, &m3)
+	tcompare(t, err, m3, Msg{4, 12, 6, "d"}, "insert after rollback")
+
+	// Named counters.
+	err = db.Read(ctxbg, func(tx *Tx) error {
+		v, err := tx.Seq("counter")
+		tcompare(t, err, v, uint64(0), "unused counter")
+		_, err = tx.NextSeq("counter")
+		tneed(t, err, ErrParam, "counter in read-only transaction")
+		return nil
+	})
+	tcheck(t, err, "read")
+	v, err := db.NextSeq(ctxbg, "counter")
+	tcompare(t, err, v, uint64(1), "next counter")
+	v, err = db.NextSeq(ctxbg, "counter")
+	tcompare(t, err, v, uint64(2), "next counter")
+	err = db.Read(ctxbg, func(tx *Tx) error {
+		v, err := tx.Seq("counter")
+		tcompare(t, err, v, uint64(2), "counter")
+
+		types, err := tx.Types()
+		tcompare(t, err, types, []string{"Msg"}, "types")
+		return nil
+	})
+	tcheck(t, err, "read")
+
+	tclose(t, db)
+
+	// A new seq field starts after the highest existing value.
+	type Msg2 struct {
+		ID     int    `bstore:"typename Msg"`
+		UID    uint32 `bstore:"seq"`
+		ModSeq int64  `bstore:"modseq"`
+		Text   string
+		Order  int `bstore:"seq"`
+	}
+	// Give an existing record a value for the new field through a type without
+	// sequences.
+	type MsgOrder struct {
+		ID     int `bstore:"typename Msg"`
+		UID    uint32
+		ModSeq int64
+		Text   string
+		Order  int
+	}
+	db, err = topen(t, path, nil, MsgOrder{})
+	tcheck(t, err, "open")
+	err = db.Update(ctxbg, &MsgOrder{ID: 2, UID: 10, ModSeq: 5, Text: "bb", Order: 20})
+	tcheck(t, err, "update")
+	tclose(t, db)
+
+	db, err = topen(t, path, nil, Msg2{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+	n2 := Msg2{Text: "e"}
+	err = db.Insert(ctxbg, &n2)
+	tcompare(t, err, n2, Msg2{5, 13, 7, "e", 21}, "insert with new seq field")
+
+	type BadType struct {
+		ID int
+		S  string `bstore:"seq"`
+	}
+	type BadPtr struct {
+		ID int
+		S  *int `bstore:"seq"`
+	}
+	type BadBoth struct {
+		ID int
+		S  int `bstore:"seq,modseq"`
+	}
+	type BadPK struct {
+		ID int `bstore:"seq"`
+	}
+	type BadName struct {
+		ID int `bstore:"typename .bstore"`
+	}
+	for _, v := range []any{BadType{}, BadPtr{}, BadBoth{}, BadPK{}, BadName{}} {
+		err := db.Register(ctxbg, v)
+		tneed(t, err, ErrType, fmt.Sprintf("register %T", v))
+	}
+}
+
+func TestAutoPK(t *testing.T) {
+	type UUIDString struct {
+		ID   string `bstore:"auto uuid"`
+		Text string
+	}
+	type UUIDv7Bytes struct {
+		ID   []byte `bstore:"auto uuidv7"`
+		Text string
+	}
+	type ULIDArray struct {
+		ID   [16]byte `bstore:"auto ulid"`
+		Text string
+	}
+	type ULIDString struct {
+		ID   string `bstore:"auto ulid"`
+		Text string
+	}
+	type ArrayRef struct {
+		ID      int
+		ArrayID [16]byte `bstore:"ref ULIDArray"`
+	}
+
+	const path = "testdata/tmp.autopk.db"
+	os.Remove(path)
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	now := func() time.Time { return tm }
+	db, err := topen(t, path, &Options{Now: now}, UUIDString{}, UUIDv7Bytes{}, ULIDArray{}, ULIDString{}, ArrayRef{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	u := UUIDString{Text: "a"}
+	err = db.Insert(ctxbg, &u)
+	tcheck(t, err, "insert")
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(u.ID) {
+		t.Fatalf("bad uuid %q", u.ID)
+	}
+	ux := UUIDString{ID: u.ID}
+	err = db.Get(ctxbg, &ux)
+	tcompare(t, err, ux, u, "get")
+
+	// Explicit values are kept.
+	u2 := UUIDString{ID: "x", Text: "b"}
+	err = db.Insert(ctxbg, &u2)
+	tcompare(t, err, u2.ID, "x", "insert with explicit pk")
+
+	// Update requires a primary key.
+	err = db.Update(ctxbg, &UUIDString{Text: "c"})
+	tneed(t, err, ErrParam, "update with zero pk")
+
+	v := UUIDv7Bytes{Text: "a"}
+	err = db.Insert(ctxbg, &v)
+	tcheck(t, err, "insert")
+	if len(v.ID) != 16 || v.ID[6]>>4 != 7 || v.ID[8]>>6 != 2 {
+		t.Fatalf("bad uuidv7 %x", v.ID)
+	}
+	if ms := int64(binary.BigEndian.Uint64(append([]byte{0, 0}, v.ID[:6]...))); ms != tm.UnixMilli() {
+		t.Fatalf("uuidv7 timestamp %d, expected %d", ms, tm.UnixMilli())
+	}
+
+	// Time-ordered IDs sort by creation time.
+	var arrays []ULIDArray
+	var strs []ULIDString
+	for i := range 3 {
+		a := ULIDArray{Text: fmt.Sprint(i)}
+		err = db.Insert(ctxbg, &a)
+		tcheck(t, err, "insert")
+		arrays = append(arrays, a)
+		s := ULIDString{Text: fmt.Sprint(i)}
+		err = db.Insert(ctxbg, &s)
+		tcheck(t, err, "insert")
+		if len(s.ID) != 26 {
+			t.Fatalf("bad ulid %q", s.ID)
+		}
+		strs = append(strs, s)
+		tm = tm.Add(time.Millisecond)
+	}
+	la, err := QueryDB[ULIDArray](ctxbg, db).SortAsc("ID").List()
+	tcompare(t, err, la, arrays, "list arrays")
+	ls, err := QueryDB[ULIDString](ctxbg, db).SortAsc("ID").List()
+	tcompare(t, err, ls, strs, "list strings")
+
+	a, err := QueryDB[ULIDArray](ctxbg, db).FilterID(arrays[1].ID).Get()
+	tcompare(t, err, a, arrays[1], "get by array pk")
+	n, err := QueryDB[ULIDArray](ctxbg, db).FilterGreater("ID", arrays[0].ID).Count()
+	tcompare(t, err, n, 2, "count greater than array pk")
+
+	// References to [16]byte primary keys.
+	r := ArrayRef{ArrayID: arrays[1].ID}
+	err = db.Insert(ctxbg, &r)
+	tcheck(t, err, "insert with reference to array pk")
+	err = db.Insert(ctxbg, &ArrayRef{ArrayID: [16]byte{1}})
+	tneed(t, err, ErrReference, "insert with reference to absent array pk")
+	rx, err := QueryDB[ArrayRef](ctxbg, db).FilterEqual("ArrayID", arrays[1].ID).Get()
+	tcompare(t, err, rx, r, "get by array index")
+	err = db.Delete(ctxbg, &arrays[1])
+	tneed(t, err, ErrReference, "delete referenced record with array pk")
+
+	if s := formatULID([16]byte{}); s != "00000000000000000000000000" {
+		t.Fatalf("got %q for zero ulid", s)
+	}
+	if s := formatULID([16]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}); s != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Fatalf("got %q for max ulid", s)
+	}
+
+	type BadInt struct {
+		ID int `bstore:"auto uuid"`
+	}
+	type BadArray struct {
+		ID [8]byte `bstore:"auto uuid"`
+	}
+	type BadGenerator struct {
+		ID string `bstore:"auto other"`
+	}
+	type BadArrayKey struct {
+		ID [8]byte
+	}
+	type BadNonPK struct {
+		ID int
+		S  string `bstore:"auto uuid"`
+	}
+	for _, v := range []any{BadInt{}, BadArray{}, BadGenerator{}, BadArrayKey{}, BadNonPK{}} {
+		err := db.Register(ctxbg, v)
+		tneed(t, err, ErrType, fmt.Sprintf("register %T", v))
+	}
+}
+
+func TestCompositePK(t *testing.T) {
+	type MemberKey struct {
+		UserID int
+		Group  string
+	}
+	type Member struct {
+		Key  MemberKey
+		Role string
+	}
+	type Grant struct {
+		ID     int
+		Member MemberKey `bstore:"ref Member"`
+		Perm   string
+	}
+
+	const path = "testdata/tmp.compositepk.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, Member{}, Grant{})
+	tcheck(t, err, "open")
+
+	m0 := Member{MemberKey{2, "b"}, "admin"}
+	m1 := Member{MemberKey{1, "b"}, "user"}
+	m2 := Member{MemberKey{1, "a"}, "user"}
+	m3 := Member{MemberKey{1, "ab"}, "user"}
+	for _, m := range []Member{m0, m1, m2, m3} {
+		err := db.Insert(ctxbg, &m)
+		tcheck(t, err, "insert")
+	}
+	err = db.Insert(ctxbg, &Member{MemberKey{2, "b"}, "user"})
+	tneed(t, err, ErrUnique, "duplicate composite key")
+	err = db.Insert(ctxbg, &Member{Role: "user"})
+	tneed(t, err, ErrZero, "zero composite key")
+	err = db.Insert(ctxbg, &Member{MemberKey{3, "a\x00"}, "user"})
+	tneed(t, err, ErrParam, "string with 0 in composite key")
+
+	x := Member{Key: MemberKey{1, "b"}}
+	err = db.Get(ctxbg, &x)
+	tcompare(t, err, x, m1, "get")
+
+	x, err = QueryDB[Member](ctxbg, db).FilterID(MemberKey{2, "b"}).Get()
+	tcompare(t, err, x, m0, "filter id")
+
+	l, err := QueryDB[Member](ctxbg, db).FilterIDs([]MemberKey{{1, "a"}, {1, "b"}}).List()
+	tcompare(t, err, l, []Member{m2, m1}, "filter ids")
+
+	// Ordered by fields of the key.
+	l, err = QueryDB[Member](ctxbg, db).List()
+	tcompare(t, err, l, []Member{m2, m3, m1, m0}, "list")
+	l, err = QueryDB[Member](ctxbg, db).SortDesc("Key").List()
+	tcompare(t, err, l, []Member{m0, m1, m3, m2}, "list desc")
+	n, err := QueryDB[Member](ctxbg, db).FilterGreater("Key", MemberKey{1, "a"}).Count()
+	tcompare(t, err, n, 3, "count greater")
+
+	x = m1
+	x.Role = "admin"
+	err = db.Update(ctxbg, &x)
+	tcheck(t, err, "update")
+
+	// References to a composite key.
+	g := Grant{Member: MemberKey{1, "b"}, Perm: "write"}
+	err = db.Insert(ctxbg, &g)
+	tcheck(t, err, "insert grant")
+	err = db.Insert(ctxbg, &Grant{Member: MemberKey{9, "b"}})
+	tneed(t, err, ErrReference, "insert grant for absent member")
+	err = db.Delete(ctxbg, &m1)
+	tneed(t, err, ErrReference, "delete referenced member")
+	gl, err := QueryDB[Grant](ctxbg, db).FilterEqual("Member", MemberKey{1, "b"}).List()
+	tcompare(t, err, gl, []Grant{g}, "grants through ref index")
+	err = db.Delete(ctxbg, &m0)
+	tcheck(t, err, "delete unreferenced member")
+
+	// Export without Go type.
+	err = db.Read(ctxbg, func(tx *Tx) error {
+		var keys []string
+		err := tx.Keys("Member", func(pk any) error {
+			keys = append(keys, fmt.Sprintf("%+v", pk))
+			return nil
+		})
+		tcompare(t, err, keys, []string{"{UserID:1 Group:a}", "{UserID:1 Group:ab}", "{UserID:1 Group:b}"}, "keys")
+
+		var fields []string
+		var records []map[string]any
+		err = tx.Records("Grant", &fields, func(r map[string]any) error {
+			records = append(records, r)
+			return nil
+		})
+		tcheck(t, err, "records")
+		tcompare(t, err, fmt.Sprintf("%+v", records[0]["Member"]), "map[Group:b UserID:1]", "record with composite key field")
+		return nil
+	})
+	tcheck(t, err, "read")
+	tclose(t, db)
+
+	// Composite key cannot change.
+	type MemberKey2 struct {
+		UserID int
+		Group  string
+		Extra  int
+	}
+	type Member2 struct {
+		Key  MemberKey2 `bstore:"typename Member"`
+		Role string
+	}
+	db, err = topen(t, path, nil, Member2{})
+	tneed(t, err, ErrIncompatible, "changing composite key")
+
+	db, err = topen(t, path, nil, Member{}, Grant{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	type BadPtr struct {
+		Key struct{ A *int }
+	}
+	type BadFloat struct {
+		Key struct{ A float64 }
+	}
+	type BadNested struct {
+		Key struct{ A struct{ B int } }
+	}
+	type BadTag struct {
+		Key struct {
+			A int `bstore:"name B"`
+		}
+	}
+	type BadEmpty struct {
+		Key struct{}
+	}
+	type BadRef struct {
+		ID  int
+		Ref struct{ UserID int } `bstore:"ref Member"`
+	}
+	for _, v := range []any{BadPtr{}, BadFloat{}, BadNested{}, BadTag{}, BadEmpty{}, BadRef{}} {
+		err := db.Register(ctxbg, v)
+		tneed(t, err, ErrType, fmt.Sprintf("register %T", v))
+	}
+}
+
+func TestOldName(t *testing.T) {
+	type T1 struct {
+		ID int    `bstore:"typename T"`
+		A  string `bstore:"index"`
+		N  int32
+	}
+	type T2 struct {
+		ID int    `bstore:"typename T"`
+		B  string `bstore:"oldname A,index"`
+		M  int64  `bstore:"oldname N"`
+	}
+	type T3 struct {
+		ID    int    `bstore:"typename T"`
+		C     string `bstore:"oldname B,index"`
+		M     int64  `bstore:"oldname N"`
+		Extra bool
+	}
+
+	const path = "testdata/tmp.oldname.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, T1{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &T1{A: "a", N: 1})
+	tcheck(t, err, "insert")
+	tclose(t, db)
+
+	db, err = topen(t, path, nil, T2{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &T2{B: "b", M: 2})
+	tcheck(t, err, "insert")
+	l2, err := QueryDB[T2](ctxbg, db).List()
+	tcompare(t, err, l2, []T2{{1, "a", 1}, {2, "b", 2}}, "list after rename")
+	x2, err := QueryDB[T2](ctxbg, db).FilterEqual("B", "a").Get()
+	tcompare(t, err, x2, T2{1, "a", 1}, "get through index on renamed field")
+	tclose(t, db)
+
+	// Registering the same type again does not add a type version, and keeps the rename.
+	db, err = topen(t, path, nil, T2{})
+	tcheck(t, err, "open")
+	tcompare(t, nil, len(db.typeNames["T"].Versions), 2, "type versions")
+	l2, err = QueryDB[T2](ctxbg, db).List()
+	tcompare(t, err, l2, []T2{{1, "a", 1}, {2, "b", 2}}, "list after reopen")
+	tclose(t, db)
+
+	// Renames are followed through multiple type versions.
+	db, err = topen(t, path, nil, T3{})
+	tcheck(t, err, "open")
+	l3, err := QueryDB[T3](ctxbg, db).List()
+	tcompare(t, err, l3, []T3{{1, "a", 1, false}, {2, "b", 2, false}}, "list after second rename")
+	tclose(t, db)
+
+	db, err = topen(t, path, nil, T3{})
+	tcheck(t, err, "open")
+	tcompare(t, nil, len(db.typeNames["T"].Versions), 3, "type versions")
+	l3, err = QueryDB[T3](ctxbg, db).List()
+	tcompare(t, err, l3, []T3{{1, "a", 1, false}, {2, "b", 2, false}}, "list after reopen")
+	tclose(t, db)
+
+	// Renamed fields are checked for compatibility.
+	type T4 struct {
+		ID int  `bstore:"typename T"`
+		D  bool `bstore:"oldname C"`
+	}
+	_, err = topen(t, path, nil, T4{})
+	tneed(t, err, ErrIncompatible, "incompatible renamed field")
+
+	db, err = topen(t, path, nil, T3{})
+	tcheck(t, err, "open")
+	defer tclose(t, db)
+
+	type BadOther struct {
+		ID int
+		A  string
+		B  string `bstore:"oldname A"`
+	}
+	type BadNested struct {
+		ID int
+		S  struct {
+			B string `bstore:"oldname A"`
+		}
+	}
+	type BadPK struct {
+		ID int `bstore:"oldname Key"`
+	}
+	for _, v := range []any{BadOther{}, BadNested{}, BadPK{}} {
+		err := db.Register(ctxbg, v)
+		tneed(t, err, ErrType, fmt.Sprintf("register %T", v))
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	const path = "testdata/tmp.migrate.db"
+	os.Remove(path)
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	opts := &Options{Now: func() time.Time { return tm }}
+	db, err := topen(t, path, opts, User{})
+	tcheck(t, err, "open")
+
+	var ran []string
+	migration := func(name string, fn func(tx *Tx) error) Migration {
+		return Migration{name, func(tx *Tx) error {
+			ran = append(ran, name)
+			return fn(tx)
+		}}
+	}
+	m1 := migration("add-admin", func(tx *Tx) error {
+		return tx.Insert(&User{Name: "admin"})
+	})
+	m2 := migration("rename-admin", func(tx *Tx) error {
+		_, err := QueryTx[User](tx).FilterNonzero(User{Name: "admin"}).UpdateField("Name", "root")
+		return err
+	})
+	err = db.Migrate(ctxbg, m1, m2)
+	tcheck(t, err, "migrate")
+	tcompare(t, nil, ran, []string{"add-admin", "rename-admin"}, "migrations run")
+
+	// Completed migrations are not run again.
+	ran = nil
+	err = db.Migrate(ctxbg, m1, m2)
+	tcheck(t, err, "migrate")
+	tcompare(t, nil, ran, []string(nil), "migrations run again")
+
+	users, err := QueryDB[User](ctxbg, db).List()
+	tcompare(t, err, users, []User{{1, "root"}}, "users")
+
+	// Failing migration is rolled back and not recorded.
+	m3 := migration("failing", func(tx *Tx) error {
+		if err := tx.Insert(&User{Name: "other"}); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	})
+	err = db.Migrate(ctxbg, m1, m2, m3)
+	if err == nil || err.Error() != `migration "failing": boom` {
+		t.Fatalf("got err %v, expected failing migration", err)
+	}
+	n, err := QueryDB[User](ctxbg, db).Count()
+	tcompare(t, err, n, 1, "users after failed migration")
+
+	err = db.Read(ctxbg, func(tx *Tx) error {
+		done, err := tx.Migrations()
+		tcompare(t, err, done, map[string]time.Time{"add-admin": tm, "rename-admin": tm}, "completed migrations")
+		return nil
+	})
+	tcheck(t, err, "read")
+
+	err = db.Migrate(ctxbg, m1, m1)
+	tneed(t, err, ErrParam, "duplicate migration")
+	err = db.Migrate(ctxbg, Migration{Name: "nofn"})
+	tneed(t, err, ErrParam, "migration without function")
+
+	tclose(t, db)
+
+	// Open runs migrations, and fails for unknown completed migrations.
+	ran = nil
+	opts.Migrations = []Migration{m1, m2, migration("third", func(tx *Tx) error { return nil })}
+	db, err = topen(t, path, opts, User{})
+	tcheck(t, err, "open")
+	tcompare(t, nil, ran, []string{"third"}, "migrations run during open")
+	tclose(t, db)
+
+	opts.Migrations = []Migration{m1, m2}
+	_, err = topen(t, path, opts, User{})
+	tneed(t, err, ErrMigration, "open with unknown completed migration")
+}
+
+func TestConvert(t *testing.T) {
+	type V1 struct {
+		ID    int   `bstore:"typename T"`
+		Count int32 `bstore:"index"`
+		Flag  bool
+		Name  string
+		Data  []byte
+		Num   uint16
+		Ptr   *int64
+	}
+	type V2 struct {
+		ID    int    `bstore:"typename T"`
+		Count uint32 `bstore:"index"`
+		Flag  int
+		Name  []byte
+		Data  string
+		Num   string
+		Ptr   *string
+	}
+
+	const path = "testdata/tmp.convert.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, V1{})
+	tcheck(t, err, "open")
+	i := int64(-3)
+	err = db.Insert(ctxbg, &V1{0, 5, true, "a", []byte("x"), 7, &i}, &V1{})
+	tcheck(t, err, "insert")
+	tclose(t, db)
+
+	db, err = topen(t, path, nil, V2{})
+	tcheck(t, err, "open")
+	s := "-3"
+	l, err := QueryDB[V2](ctxbg, db).List()
+	tcompare(t, err, l, []V2{{1, 5, 1, []byte("a"), "x", "7", &s}, {ID: 2}}, "list after conversion")
+	x, err := QueryDB[V2](ctxbg, db).FilterEqual("Count", uint32(5)).Get()
+	tcompare(t, err, x, l[0], "get through recreated index")
+
+	// All records have been rewritten to the new type version.
+	err = db.Read(ctxbg, func(tx *Tx) error {
+		return tx.btx.Bucket([]byte("T")).Bucket([]byte("records")).ForEach(func(bk, bv []byte) error {
+			if bv[0] != 2 {
+				t.Fatalf("record %x has type version %d, expected 2", bk, bv[0])
+			}
+			return nil
+		})
+	})
+	tcheck(t, err, "read")
+	tclose(t, db)
+
+	// Values that cannot be converted fail the registration, listing the records.
+	os.Remove(path)
+	db, err = topen(t, path, nil, V1{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &V1{Count: 1}, &V1{Count: -1}, &V1{Count: 2, Flag: true}, &V1{Count: -2})
+	tcheck(t, err, "insert")
+	tclose(t, db)
+	_, err = topen(t, path, nil, V2{})
+	tneed(t, err, ErrIncompatible, "open with values that do not convert")
+	if !strings.Contains(err.Error(), "primary keys [2 4]") {
+		t.Fatalf("error %q does not mention primary keys 2 and 4", err)
+	}
+
+	// Nothing was changed.
+	db, err = topen(t, path, nil, V1{})
+	tcheck(t, err, "open")
+	n, err := QueryDB[V1](ctxbg, db).FilterEqual("Count", int32(-1)).Count()
+	tcompare(t, err, n, 1, "count after failed conversion")
+	tclose(t, db)
+
+	type S1 struct {
+		ID int `bstore:"typename S"`
+		S  string
+		B  int
+	}
+	type S2 struct {
+		ID int `bstore:"typename S"`
+		S  int64
+		B  bool
+	}
+	for _, v := range []S1{{S: "007"}, {S: "1e3"}, {S: "x"}, {B: 2}} {
+		os.Remove(path)
+		db, err := topen(t, path, nil, S1{})
+		tcheck(t, err, "open")
+		err = db.Insert(ctxbg, &v)
+		tcheck(t, err, "insert")
+		tclose(t, db)
+		_, err = topen(t, path, nil, S2{})
+		tneed(t, err, ErrIncompatible, fmt.Sprintf("open with %v", v))
+	}
+
+	// Signedness changes can narrow, each value must fit. Narrowing without
+	// signedness change is not allowed.
+	type N1 struct {
+		ID int `bstore:"typename N"`
+		A  int64
+		B  uint8
+	}
+	type N2 struct {
+		ID int   `bstore:"typename N"`
+		A  uint8 // Narrowing with signedness change.
+		B  uint8
+	}
+	type N3 struct {
+		ID int   `bstore:"typename N"`
+		A  int32 // Narrowing without signedness change.
+		B  uint8
+	}
+	type N4 struct {
+		ID int `bstore:"typename N"`
+		A  uint8
+		B  int16 // Widening with signedness change.
+	}
+	os.Remove(path)
+	db, err = topen(t, path, nil, N1{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &N1{A: 5, B: 200}, &N1{A: 300}, &N1{A: -1})
+	tcheck(t, err, "insert")
+	tclose(t, db)
+	_, err = topen(t, path, nil, N3{})
+	tneed(t, err, ErrIncompatible, "open with narrowing without signedness change")
+	_, err = topen(t, path, nil, N2{})
+	tneed(t, err, ErrIncompatible, "open with narrowing values that do not fit")
+	if !strings.Contains(err.Error(), "primary keys [2 3]") {
+		t.Fatalf("error %q does not mention primary keys 2 and 3", err)
+	}
+	db, err = topen(t, path, nil, N1{})
+	tcheck(t, err, "open")
+	n, err = QueryDB[N1](ctxbg, db).FilterNotEqual("A", int64(5)).Delete()
+	tcompare(t, err, n, 2, "delete values that do not fit")
+	tclose(t, db)
+	db, err = topen(t, path, nil, N2{})
+	tcheck(t, err, "open with narrowing signedness change")
+	nv2, err := QueryDB[N2](ctxbg, db).Get()
+	tcompare(t, err, nv2, N2{1, 5, 200}, "converted record")
+	tclose(t, db)
+	db, err = topen(t, path, nil, N4{})
+	tcheck(t, err, "open with widening signedness change")
+	nv4, err := QueryDB[N4](ctxbg, db).Get()
+	tcompare(t, err, nv4, N4{1, 5, 200}, "converted record")
+	tclose(t, db)
+}
+
+func TestPKChange(t *testing.T) {
+	type User1 struct {
+		ID   int32  `bstore:"typename User"`
+		Name string `bstore:"unique"`
+	}
+	type Session1 struct {
+		ID     int64 `bstore:"typename Session"`
+		UserID int32 `bstore:"ref User"`
+	}
+	type User2 struct {
+		ID   int64  `bstore:"typename User"`
+		Name string `bstore:"unique"`
+	}
+	type Session2 struct {
+		ID     int64 `bstore:"typename Session"`
+		UserID int64 `bstore:"ref User"`
+	}
+
+	const path = "testdata/tmp.pkchange.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, User1{}, Session1{})
+	tcheck(t, err, "open")
+	u0, u1 := User1{Name: "a"}, User1{Name: "b"}
+	err = db.Insert(ctxbg, &u0, &u1)
+	tcheck(t, err, "insert users")
+	err = db.Delete(ctxbg, &u1)
+	tcheck(t, err, "delete user")
+	err = db.Insert(ctxbg, &Session1{UserID: u0.ID})
+	tcheck(t, err, "insert session")
+	tclose(t, db)
+
+	// Referencing types must change along.
+	_, err = topen(t, path, nil, User2{}, Session1{})
+	tneed(t, err, ErrType, "open with reference of old type")
+
+	db, err = topen(t, path, nil, User2{}, Session2{})
+	tcheck(t, err, "open with new primary key type")
+	users, err := QueryDB[User2](ctxbg, db).List()
+	tcompare(t, err, users, []User2{{1, "a"}}, "list users")
+	u, err := QueryDB[User2](ctxbg, db).FilterEqual("Name", "a").Get()
+	tcompare(t, err, u, users[0], "get through recreated index")
+	n, err := QueryDB[Session2](ctxbg, db).FilterEqual("UserID", int64(1)).Count()
+	tcompare(t, err, n, 1, "sessions through recreated reference index")
+	err = db.Delete(ctxbg, &u)
+	tneed(t, err, ErrReference, "delete referenced user")
+
+	// Autoincrement continues after the highest key ever used.
+	nu := User2{Name: "c"}
+	err = db.Insert(ctxbg, &nu)
+	tcompare(t, err, nu.ID, int64(3), "autoincrement after key change")
+	tclose(t, db)
+
+	// Integer to string primary keys, and back.
+	type User3 struct {
+		ID   string `bstore:"typename User"`
+		Name string `bstore:"unique"`
+	}
+	type Session3 struct {
+		ID     int64  `bstore:"typename Session"`
+		UserID string `bstore:"ref User"`
+	}
+	db, err = topen(t, path, nil, User3{}, Session3{})
+	tcheck(t, err, "open with string primary key")
+	u3, err := QueryDB[User3](ctxbg, db).FilterID("1").Get()
+	tcompare(t, err, u3, User3{"1", "a"}, "get by string key")
+	err = db.Insert(ctxbg, &User3{"x", "d"})
+	tcheck(t, err, "insert user")
+	tclose(t, db)
+
+	_, err = topen(t, path, nil, User2{}, Session2{})
+	tneed(t, err, ErrIncompatible, "open with string key that is not an integer")
+	if !strings.Contains(err.Error(), "primary keys [x]") {
+		t.Fatalf("error %q does not mention primary key x", err)
+	}
+
+	// Negative keys cannot become unsigned.
+	type N1 struct {
+		ID int16 `bstore:"typename N,noauto"`
+	}
+	type N2 struct {
+		ID uint32 `bstore:"typename N"`
+	}
+	type N3 struct {
+		ID float64 `bstore:"typename N"`
+	}
+	os.Remove(path)
+	db, err = topen(t, path, nil, N1{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &N1{-1}, &N1{2})
+	tcheck(t, err, "insert")
+	tclose(t, db)
+	_, err = topen(t, path, nil, N2{})
+	tneed(t, err, ErrIncompatible, "open with negative key to unsigned")
+	_, err = topen(t, path, nil, N3{})
+	tneed(t, err, ErrType, "open with float primary key")
+}
+
+func TestUpgradeRecords(t *testing.T) {
+	type V1 struct {
+		ID   int `bstore:"typename T"`
+		Name string
+	}
+	type V2 struct {
+		ID   int `bstore:"typename T"`
+		Name string
+		N    int `bstore:"index"`
+	}
+	type V3 struct {
+		ID   int `bstore:"typename T"`
+		Name string
+		N    int `bstore:"index"`
+		B    bool
+	}
+
+	const path = "testdata/tmp.upgraderecords.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, V1{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &V1{Name: "a"}, &V1{Name: "b"})
+	tcheck(t, err, "insert")
+	tclose(t, db)
+	db, err = topen(t, path, nil, V2{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &V2{Name: "c", N: 1})
+	tcheck(t, err, "insert")
+	tclose(t, db)
+	db, err = topen(t, path, nil, V3{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &V3{Name: "d", B: true})
+	tcheck(t, err, "insert")
+
+	_, err = db.UpgradeRecords(ctxbg, "Other")
+	tneed(t, err, ErrType, "upgrade unregistered type")
+
+	// A query in the same transaction continues after the records are rewritten.
+	err = db.Write(ctxbg, func(tx *Tx) error {
+		q := QueryTx[V3](tx)
+		v, err := q.Next()
+		tcompare(t, err, v.ID, 1, "first record")
+		r, err := tx.UpgradeRecords("T")
+		tcompare(t, err, r, UpgradeResult{3, map[uint32]int{1: 2, 2: 1}, []uint32{1, 2}}, "upgrade")
+		var ids []int
+		for len(ids) < 10 {
+			v, err := q.Next()
+			if err == ErrAbsent {
+				break
+			}
+			tcheck(t, err, "next")
+			ids = append(ids, v.ID)
+		}
+		tcompare(t, nil, ids, []int{2, 3, 4}, "records after upgrade")
+		return nil
+	})
+	tcheck(t, err, "write")
+	r, err := db.UpgradeRecords(ctxbg, "T")
+	tcompare(t, err, r, UpgradeResult{3, map[uint32]int{}, nil}, "upgrade again")
+	tclose(t, db)
+
+	// Only the current type version is left, and records still parse.
+	db, err = topen(t, path, nil, V3{})
+	tcheck(t, err, "open")
+	err = db.Read(ctxbg, func(tx *Tx) error {
+		var versions []uint32
+		err := tx.btx.Bucket([]byte("T")).Bucket([]byte("types")).ForEach(func(bk, bv []byte) error {
+			versions = append(versions, binary.BigEndian.Uint32(bk))
+			return nil
+		})
+		tcompare(t, err, versions, []uint32{3}, "type versions")
+		return nil
+	})
+	tcheck(t, err, "read")
+	l, err := QueryDB[V3](ctxbg, db).List()
+	tcompare(t, err, l, []V3{{1, "a", 0, false}, {2, "b", 0, false}, {3, "c", 1, false}, {4, "d", 0, true}}, "list")
+	n, err := QueryDB[V3](ctxbg, db).FilterEqual("N", 0).Count()
+	tcompare(t, err, n, 3, "count through index")
+	tclose(t, db)
+}
+
+func TestBackfill(t *testing.T) {
+	type V1 struct {
+		ID   int `bstore:"typename T"`
+		Name string
+	}
+	type V2 struct {
+		ID      int `bstore:"typename T"`
+		Name    string
+		Count   int       `bstore:"nonzero,default 3 backfill,index"`
+		Other   int       `bstore:"default 4"`
+		Created time.Time `bstore:"default now backfill"`
+	}
+
+	const path = "testdata/tmp.backfill.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, V1{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &V1{Name: "a"}, &V1{Name: "b"})
+	tcheck(t, err, "insert")
+	tclose(t, db)
+
+	// Without backfill, a new nonzero field cannot be added.
+	type V2b struct {
+		ID    int `bstore:"typename T"`
+		Name  string
+		Count int `bstore:"nonzero,default 3"`
+	}
+	_, err = topen(t, path, nil, V2b{})
+	tneed(t, err, ErrZero, "open with new nonzero field without backfill")
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	db, err = topen(t, path, &Options{Now: func() time.Time { return now }}, V2{})
+	tcheck(t, err, "open with backfill")
+	l, err := QueryDB[V2](ctxbg, db).List()
+	tcompare(t, err, l, []V2{{1, "a", 3, 0, now}, {2, "b", 3, 0, now}}, "list with backfilled values")
+	n, err := QueryDB[V2](ctxbg, db).FilterEqual("Count", 3).Count()
+	tcompare(t, err, n, 2, "count through index")
+	tclose(t, db)
+
+	// Backfill is only for top-level fields.
+	type Sub struct {
+		N int `bstore:"default 1 backfill"`
+	}
+	type V3 struct {
+		ID  int
+		Sub Sub
+	}
+	_, err = topen(t, path, nil, V3{})
+	tneed(t, err, ErrType, "open with backfill in nested struct")
+}
+
+func TestSchemas(t *testing.T) {
+	type Group struct {
+		ID   int64 `bstore:"noauto"`
+		Name string
+	}
+	type Node struct {
+		Name     string `bstore:"maxlen 10"`
+		Children []Node
+	}
+	type V1 struct {
+		ID      int `bstore:"typename User"`
+		Name    string
+		GroupID int64 `bstore:"ref Group cascade,index"`
+	}
+	type V2 struct {
+		ID       int    `bstore:"typename User"`
+		FullName string `bstore:"oldname Name,unique,nonzero"`
+		GroupID  int64  `bstore:"ref Group cascade,index"`
+		Role     string `bstore:"enum admin user,default user backfill"`
+		Tree     *Node
+		Extra    map[string][]byte
+	}
+
+	const path = "testdata/tmp.schemas.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, V1{}, Group{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &Group{1, "g"})
+	tcheck(t, err, "insert group")
+	err = db.Insert(ctxbg, &V1{Name: "a", GroupID: 1}, &V1{Name: "b", GroupID: 1})
+	tcheck(t, err, "insert users")
+	tclose(t, db)
+	db, err = topen(t, path, nil, V2{}, Group{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &V2{FullName: "c", GroupID: 1})
+	tcheck(t, err, "insert user")
+
+	err = db.Read(ctxbg, func(tx *Tx) error {
+		l, err := tx.Schemas("User")
+		tcheck(t, err, "schemas")
+		exp := []Schema{
+			{
+				Type:    "User",
+				Version: 1,
+				Records: 0, // Rewritten by backfill.
+				Fields: []SchemaField{
+					{Name: "ID", Kind: "int", Type: "int"},
+					{Name: "Name", Kind: "string", Type: "string"},
+					{Name: "GroupID", Kind: "int64", Type: "int64", Tags: []string{"ref Group cascade"}},
+				},
+				Indices: []SchemaIndex{
+					{Name: "GroupID", Fields: []string{"GroupID"}},
+					{Name: "GroupID:Group", Fields: []string{"GroupID"}},
+				},
+			},
+			{
+				Type:    "User",
+				Version: 2,
+				Current: true,
+				Records: 3,
+				Fields: []SchemaField{
+					{Name: "ID", Kind: "int", Type: "int"},
+					{Name: "FullName", Kind: "string", Type: "string", Tags: []string{"nonzero", "oldname Name"}},
+					{Name: "GroupID", Kind: "int64", Type: "int64", Tags: []string{"ref Group cascade"}},
+					{Name: "Role", Kind: "string", Type: "string", Tags: []string{"default user", "enum admin user"}},
+					{Name: "Tree", Kind: "struct", Type: "*struct", Fields: []SchemaField{
+						{Name: "Name", Kind: "string", Type: "string", Tags: []string{"maxlen 10"}},
+						{Name: "Children", Kind: "slice", Type: "[]struct"},
+					}},
+					{Name: "Extra", Kind: "map", Type: "map[string][]byte"},
+				},
+				Indices: []SchemaIndex{
+					{Name: "FullName", Unique: true, Fields: []string{"FullName"}},
+					{Name: "GroupID", Fields: []string{"GroupID"}},
+					{Name: "GroupID:Group", Fields: []string{"GroupID"}},
+				},
+			},
+		}
+		tcompare(t, nil, l, exp, "schemas of user")
+
+		l, err = tx.Schemas("Group")
+		exp = []Schema{{
+			Type:    "Group",
+			Version: 1,
+			Current: true,
+			Records: 1,
+			Fields: []SchemaField{
+				{Name: "ID", Kind: "int64", Type: "int64", Tags: []string{"noauto"}},
+				{Name: "Name", Kind: "string", Type: "string"},
+			},
+			ReferencedBy: []string{"User"},
+		}}
+		tcompare(t, err, l, exp, "schemas of group")
+
+		_, err = tx.Schemas("Absent")
+		tneed(t, err, ErrStore, "schemas of absent type")
+		return nil
+	})
+	tcheck(t, err, "read")
+	tclose(t, db)
+}
+
+func TestRegisterDryRun(t *testing.T) {
+	type Group struct {
+		ID int
+	}
+	type V1 struct {
+		ID      int `bstore:"typename User"`
+		Name    string
+		Count   int32 `bstore:"index"`
+		Old     string
+		GroupID int
+	}
+	type V2 struct {
+		ID       int    `bstore:"typename User"`
+		FullName string `bstore:"oldname Name,unique"`
+		Count    int64  `bstore:"index"`
+		Role     string `bstore:"nonzero,default user backfill"`
+		GroupID  int    `bstore:"ref Group"`
+	}
+
+	const path = "testdata/tmp.registerdryrun.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, V1{}, Group{})
+	tcheck(t, err, "open")
+	err = db.Insert(ctxbg, &Group{}, &V1{Name: "a", GroupID: 1}, &V1{Name: "b"})
+	tcheck(t, err, "insert")
+
+	diffs, err := db.RegisterDryRun(ctxbg, V1{}, Group{})
+	tcompare(t, err, diffs, []SchemaDiff{{Type: "User", Version: 1}, {Type: "Group", Version: 1}}, "dry run without changes")
+
+	diffs, err = db.RegisterDryRun(ctxbg, V2{}, Group{})
+	exp := []SchemaDiff{
+		{
+			Type:           "User",
+			Changed:        true,
+			Version:        2,
+			FieldsAdded:    []string{"Role"},
+			FieldsRemoved:  []string{"Old"},
+			FieldsRenamed:  map[string]string{"FullName": "Name"},
+			FieldsChanged:  []string{"Count", "GroupID"},
+			IndicesCreated: []string{"Count", "FullName", "GroupID:Group"},
+			IndicesDropped: []string{"Count"},
+			Checks:         []string{"nonzero Role", "backfill Role", "ref GroupID Group", "unique FullName"},
+		},
+		{Type: "Group", Changed: true, Version: 2},
+	}
+	tcompare(t, err, diffs, exp, "dry run with changes")
+
+	// Failing checks are returned, with the changes.
+	err = db.Insert(ctxbg, &V1{Name: "a"})
+	tcheck(t, err, "insert duplicate name")
+	diffs, err = db.RegisterDryRun(ctxbg, V2{}, Group{})
+	tneed(t, err, ErrUnique, "dry run with duplicate values")
+	tcompare(t, nil, diffs, exp, "diffs of failed dry run")
+
+	// New types are described too.
+	type Other struct {
+		ID   int
+		Name string `bstore:"unique"`
+	}
+	diffs, err = db.RegisterDryRun(ctxbg, Other{})
+	tcompare(t, err, diffs, []SchemaDiff{{Type: "Other", New: true, Changed: true, Version: 1, FieldsAdded: []string{"ID", "Name"}, IndicesCreated: []string{"Name"}}}, "dry run for new type")
+
+	// Nothing changed in the database.
+	err = db.Read(ctxbg, func(tx *Tx) error {
+		l, err := tx.Types()
+		tcompare(t, err, l, []string{"Group", "User"}, "types")
+		schemas, err := tx.Schemas("User")
+		tcompare(t, err, len(schemas), 1, "user schemas")
+		return nil
+	})
+	tcheck(t, err, "read")
+	n, err := QueryDB[V1](ctxbg, db).Count()
+	tcompare(t, err, n, 3, "count")
+	tclose(t, db)
+}
+
+func TestDynamic(t *testing.T) {
+	group, err := NewDynamicType("Group", []DynamicField{
+		{Name: "ID", Kind: "int64"},
+		{Name: "Name", Kind: "string", Tag: "unique"},
+	})
+	tcheck(t, err, "new dynamic type")
+	user, err := NewDynamicType("User", []DynamicField{
+		{Name: "ID", Kind: "string", Tag: "auto uuid"},
+		{Name: "GroupID", Kind: "int64", Tag: "ref Group,nonzero"},
+		{Name: "Tags", Kind: "[]string"},
+		{Name: "Score", Kind: "*float64"},
+		{Name: "Created", Kind: "time", Tag: "autocreate"},
+	})
+	tcheck(t, err, "new dynamic type")
+
+	const path = "testdata/tmp.dynamic.db"
+	os.Remove(path)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	db, err := topen(t, path, &Options{Now: func() time.Time { return now }}, group, user)
+	tcheck(t, err, "open")
+
+	g := map[string]any{"Name": "admins"}
+	err = db.InsertRecord(ctxbg, "Group", g)
+	tcompare(t, err, g, map[string]any{"ID": int64(1), "Name": "admins"}, "inserted group")
+
+	// Numbers from e.g. JSON are converted if they fit.
+	u := map[string]any{"GroupID": float64(1), "Tags": []any{"a", "b"}}
+	err = db.InsertRecord(ctxbg, "User", u)
+	tcheck(t, err, "insert user")
+	id, ok := u["ID"].(string)
+	if !ok || len(id) != 36 {
+		t.Fatalf("user got id %v, expected generated uuid", u["ID"])
+	}
+	exp := map[string]any{"ID": id, "GroupID": int64(1), "Tags": []string{"a", "b"}, "Score": (*float64)(nil), "Created": now}
+	tcompare(t, nil, u, exp, "inserted user")
+
+	err = db.InsertRecord(ctxbg, "User", map[string]any{"GroupID": 1.5})
+	tneed(t, err, ErrParam, "insert with number that does not fit")
+	err = db.InsertRecord(ctxbg, "User", map[string]any{"GroupID": 1, "Bogus": 1})
+	tneed(t, err, ErrParam, "insert with unknown field")
+	err = db.InsertRecord(ctxbg, "User", map[string]any{"GroupID": "1"})
+	tneed(t, err, ErrParam, "insert with string for integer")
+	err = db.InsertRecord(ctxbg, "User", map[string]any{})
+	tneed(t, err, ErrZero, "insert with nonzero field missing")
+	err = db.InsertRecord(ctxbg, "User", map[string]any{"GroupID": 2})
+	tneed(t, err, ErrReference, "insert with reference to absent group")
+	err = db.InsertRecord(ctxbg, "Other", map[string]any{})
+	tneed(t, err, ErrType, "insert for unregistered type")
+
+	// Only fields present are updated.
+	err = db.UpdateRecord(ctxbg, "User", map[string]any{"ID": id, "Score": 2.5})
+	tcheck(t, err, "update user")
+	r, err := db.GetRecord(ctxbg, "User", id)
+	tcheck(t, err, "get user")
+	if score, ok := r["Score"].(*float64); !ok || score == nil || *score != 2.5 {
+		t.Fatalf("score after update is %v, expected 2.5", r["Score"])
+	}
+	if !reflect.DeepEqual(r["Tags"], []string{"a", "b"}) {
+		t.Fatalf("tags after update is %v, expected unchanged", r["Tags"])
+	}
+	err = db.UpdateRecord(ctxbg, "User", map[string]any{"Score": 1})
+	tneed(t, err, ErrParam, "update without primary key")
+
+	err = db.DeleteRecord(ctxbg, "Group", 1)
+	tneed(t, err, ErrReference, "delete referenced group")
+	err = db.DeleteRecord(ctxbg, "User", id)
+	tcheck(t, err, "delete user")
+	_, err = db.GetRecord(ctxbg, "User", id)
+	tneed(t, err, ErrAbsent, "get deleted user")
+
+	// Works for Go struct types too.
+	type Struct struct {
+		ID   int
+		Name string `bstore:"name Title"`
+	}
+	err = db.Register(ctxbg, Struct{})
+	tcheck(t, err, "register")
+	m := map[string]any{"Title": "x"}
+	err = db.InsertRecord(ctxbg, "Struct", m)
+	tcheck(t, err, "insert record for struct type")
+	x := Struct{ID: 1}
+	err = db.Get(ctxbg, &x)
+	tcompare(t, err, x, Struct{1, "x"}, "get struct")
+	tclose(t, db)
+
+	// Schema changes work like for Go types.
+	group2, err := NewDynamicType("Group", []DynamicField{
+		{Name: "ID", Kind: "int64"},
+		{Name: "Title", Kind: "string", Tag: "oldname Name,unique"},
+		{Name: "Size", Kind: "int32", Tag: "default 10 backfill"},
+	})
+	tcheck(t, err, "new dynamic type")
+	db, err = topen(t, path, nil, group2, user)
+	tcheck(t, err, "open with changed dynamic type")
+	r, err = db.GetRecord(ctxbg, "Group", 1)
+	tcompare(t, err, r, map[string]any{"ID": int64(1), "Title": "admins", "Size": int32(10)}, "get group after schema change")
+	tclose(t, db)
+
+	bad := [][]DynamicField{
+		{},
+		{{Name: "id", Kind: "int"}},
+		{{Name: "ID", Kind: "int"}, {Name: "ID", Kind: "int"}},
+		{{Name: "ID", Kind: "complex"}},
+		{{Name: "ID", Kind: "int", Tag: "typename Other"}},
+		{{Name: "ID", Kind: "int"}, {Name: "F", Kind: "int", Tag: "bogus"}},
+		{{Name: "ID", Kind: "float64"}},
+	}
+	for _, fields := range bad {
+		_, err := NewDynamicType("T", fields)
+		if err == nil {
+			t.Fatalf("new dynamic type with fields %v: expected error", fields)
+		}
+	}
+}
+
+func TestQueryRecords(t *testing.T) {
+	type Item struct {
+		ID    int64
+		Kind  string `bstore:"index Kind+Size"`
+		Size  int32
+		Price float64
+		Added time.Time
+	}
+
+	const path = "testdata/tmp.queryrecords.db"
+	os.Remove(path)
+	db, err := topen(t, path, nil, Item{})
+	tcheck(t, err, "open")
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := []Item{
+		{0, "a", 1, 1.5, t0},
+		{0, "b", 2, 2.5, t0.Add(time.Hour)},
+		{0, "a", 3, 0.5, t0.Add(2 * time.Hour)},
+		{0, "c", 1, 3.5, t0.Add(3 * time.Hour)},
+		{0, "a", 1, 4.5, t0.Add(4 * time.Hour)},
+	}
+	for i := range items {
+		err := db.Insert(ctxbg, &items[i])
+		tcheck(t, err, "insert")
+	}
+	tclose(t, db)
+
+	// Reopen without registering types, like a tool would.
+	db, err = topen(t, path, nil)
+	tcheck(t, err, "open without types")
+	defer tclose(t, db)
+
+	query := func(q RecordQuery) (ids []int64, stats Stats) {
+		t.Helper()
+		err := db.Read(ctxbg, func(tx *Tx) error {
+			ostats := tx.Stats()
+			var fields []string
+			err := tx.QueryRecords("Item", q, &fields, func(r map[string]any) error {
+				ids = append(ids, r["ID"].(int64))
+				return nil
+			})
+			tcompare(t, nil, fields, []string{"ID", "Kind", "Size", "Price", "Added"}, "fields")
+			stats = tx.Stats().Sub(ostats)
+			return err
+		})
+		tcheck(t, err, "query records")
+		return
+	}
+
+	ids, stats := query(RecordQuery{})
+	tcompare(t, nil, ids, []int64{1, 2, 3, 4, 5}, "all records")
+	tcompare(t, nil, stats.PlanTableScan, uint(1), "table scan")
+
+	ids, stats = query(RecordQuery{Filters: []RecordFilter{{Field: "ID", Op: "in", Value: []any{4.0, 2, 2, 9}}}})
+	tcompare(t, nil, ids, []int64{2, 4}, "primary key in")
+	tcompare(t, nil, stats.PlanPK, uint(1), "plan pk")
+	tcompare(t, nil, stats.Records.Cursor, uint(0), "no cursor for primary keys")
+
+	ids, stats = query(RecordQuery{Filters: []RecordFilter{{Field: "Kind", Op: "=", Value: "a"}, {Field: "Size", Op: "=", Value: 1}}})
+	tcompare(t, nil, ids, []int64{1, 5}, "index equal")
+	tcompare(t, nil, stats.PlanIndexScan, uint(1), "plan index scan")
+	tcompare(t, nil, stats.LastIndex, "Kind+Size", "index used")
+	tcompare(t, nil, stats.Records.Cursor, uint(0), "no table scan")
+
+	ids, stats = query(RecordQuery{Filters: []RecordFilter{{Field: "Kind", Op: "in", Value: []string{"c", "b"}}, {Field: "Price", Op: ">", Value: 3}}})
+	tcompare(t, nil, ids, []int64{4}, "index prefix in with compare")
+	tcompare(t, nil, stats.PlanIndexScan, uint(1), "plan index scan")
+
+	ids, _ = query(RecordQuery{Filters: []RecordFilter{{Field: "Added", Op: ">=", Value: t0.Add(time.Hour)}, {Field: "Kind", Op: "!=", Value: "c"}}})
+	tcompare(t, nil, ids, []int64{2, 3, 5}, "compare time and not equal")
+
+	// Queries from JSON have times as strings.
+	var jq RecordQuery
+	err = json.Unmarshal([]byte(`{"Filters": [{"Field": "Added", "Op": "<", "Value": "2024-01-01T02:00:00Z"}, {"Field": "Added", "Op": "in", "Value": ["2024-01-01T00:00:00Z", "2024-01-01T01:00:00.000Z"]}]}`), &jq)
+	tcheck(t, err, "parsing json query")
+	ids, _ = query(jq)
+	tcompare(t, nil, ids, []int64{1, 2}, "compare time from json")
+
+	ids, stats = query(RecordQuery{Orders: []RecordOrder{{Field: "ID", Desc: true}}, Limit: 2})
+	tcompare(t, nil, ids, []int64{5, 4}, "descending by primary key with limit")
+	tcompare(t, nil, stats.Records.Cursor, uint(2), "stopped at limit")
+	tcompare(t, nil, stats.Sort, uint(0), "no sort")
+
+	ids, stats = query(RecordQuery{Orders: []RecordOrder{{Field: "Size"}, {Field: "Price", Desc: true}}, Limit: 4})
+	tcompare(t, nil, ids, []int64{5, 4, 1, 2}, "sorted with limit")
+	tcompare(t, nil, stats.Sort, uint(1), "sort")
+
+	bad := []RecordQuery{
+		{Filters: []RecordFilter{{Field: "Bogus", Op: "=", Value: 1}}},
+		{Filters: []RecordFilter{{Field: "Size", Op: "=", Value: "x"}}},
+		{Filters: []RecordFilter{{Field: "Size", Op: "=", Value: 1.5}}},
+		{Filters: []RecordFilter{{Field: "Size", Op: "~", Value: 1}}},
+		{Filters: []RecordFilter{{Field: "Size", Op: "in", Value: 1}}},
+		{Filters: []RecordFilter{{Field: "Added", Op: "=", Value: "2024-01-01"}}},
+		{Orders: []RecordOrder{{Field: "Bogus"}}},
+	}
+	for _, q := range bad {
+		var fields []string
+		err := db.QueryRecords(ctxbg, "Item", q, &fields, func(r map[string]any) error { return nil })
+		tneed(t, err, ErrParam, "bad query")
+	}
+	var fields []string
+	err = db.QueryRecords(ctxbg, "Other", RecordQuery{}, &fields, func(r map[string]any) error { return nil })
+	tneed(t, err, ErrStore, "query absent type")
+}
+
 func TestCreateIndex(t *testing.T) {
 	type User struct {
 		ID   int
@@ -1736,21 +3998,27 @@ func TestCompatible(t *testing.T) {
 		Other T
 	}
 
-	topenCompatible(t, Base[int64]{}, ErrIncompatible, &Base[int]{}, &Base[uint64]{})
+	topenCompatible(t, Base[int64]{}, ErrIncompatible, &Base[int]{})
 	topenCompatible(t, Base[int32]{}, nil, &Base[int]{}, &Base[int64]{})
-	topenCompatible(t, Base[int32]{}, ErrIncompatible, &Base[uint32]{}, &Base[int16]{})
+	topenCompatible(t, Base[int32]{}, ErrIncompatible, &Base[int16]{})
 	topenCompatible(t, Base[int16]{}, nil, &Base[int]{}, &Base[int64]{})
-	topenCompatible(t, Base[int16]{}, ErrIncompatible, &Base[uint16]{}, &Base[int8]{})
+	topenCompatible(t, Base[int16]{}, ErrIncompatible, &Base[int8]{})
 	topenCompatible(t, Base[int8]{}, nil, &Base[int]{}, &Base[int16]{})
-	topenCompatible(t, Base[int8]{}, ErrIncompatible, &Base[uint8]{}, &Base[string]{})
 
-	topenCompatible(t, Base[uint64]{}, ErrIncompatible, &Base[uint]{}, &Base[int64]{})
+	topenCompatible(t, Base[uint64]{}, ErrIncompatible, &Base[uint]{})
 	topenCompatible(t, Base[uint32]{}, nil, &Base[uint]{}, &Base[uint64]{})
-	topenCompatible(t, Base[uint32]{}, ErrIncompatible, &Base[int32]{}, &Base[uint16]{})
+	topenCompatible(t, Base[uint32]{}, ErrIncompatible, &Base[uint16]{})
 	topenCompatible(t, Base[uint16]{}, nil, &Base[uint]{}, &Base[uint64]{})
-	topenCompatible(t, Base[uint16]{}, ErrIncompatible, &Base[int16]{}, &Base[uint8]{})
+	topenCompatible(t, Base[uint16]{}, ErrIncompatible, &Base[uint8]{})
 	topenCompatible(t, Base[uint8]{}, nil, &Base[uint]{}, &Base[uint16]{})
-	topenCompatible(t, Base[uint8]{}, ErrIncompatible, &Base[int8]{}, &Base[string]{})
+
+	// Conversions that rewrite records.
+	topenCompatible(t, Base[int64]{}, nil, &Base[uint64]{}, &Base[uint8]{}, &Base[string]{}, &Base[bool]{})
+	topenCompatible(t, Base[int8]{}, nil, &Base[uint16]{})
+	topenCompatible(t, Base[uint8]{}, nil, &Base[int8]{}, &Base[string]{})
+	topenCompatible(t, Base[string]{}, nil, &Base[[]byte]{}, &Base[int]{}, &Base[uint16]{})
+	topenCompatible(t, Base[bool]{}, nil, &Base[int]{}, &Base[uint8]{})
+	topenCompatible(t, Base[float64]{}, ErrIncompatible, &Base[int64]{}, &Base[string]{}, &Base[bool]{})
 
 	topenCompatible(t, Base[map[int]int16]{}, nil, &Base[map[int64]int32]{})
 	topenCompatible(t, Base[map[string]struct{}]{}, ErrIncompatible, &Base[string]{}, &Base[map[int]struct{}]{}, &Base[map[string]string]{})
@@ -16,11 +16,11 @@ func newStoreTags(tag string, isPK bool) (storeTags, error) {
 	for _, s := range l {
 		w := strings.SplitN(s, " ", 2)
 		switch w[0] {
-		case "noauto", "typename":
+		case "noauto", "typename", "auto":
 			if !isPK {
 				return nil, fmt.Errorf("%w: cannot have tag %q for non-primary key", ErrType, w[0])
 			}
-		case "index", "unique", "default", "-":
+		case "index", "unique", "default", "-", "enum", "min", "max", "maxlen", "pattern", "autocreate", "autoupdate", "version", "seq", "modseq", "oldname":
 			if isPK {
 				return nil, fmt.Errorf("%w: cannot have tag %q on primary key", ErrType, w[0])
 			}
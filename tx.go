@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math"
 	"reflect"
+	"strings"
+	"time"
 
 	bolt "go.etcd.io/bbolt"
 )
@@ -137,7 +140,7 @@ func (tx *Tx) updateIndices(tv *typeVersion, pk []byte, ov, v reflect.Value) err
 				if idx.Unique {
 					tx.stats.Index.Cursor++
 					if xk, _ := ib.Cursor().Seek(ik.pre); xk != nil && bytes.HasPrefix(xk, ik.pre) {
-						return fmt.Errorf("%w: %q", ErrUnique, idx.Name)
+						return idx.uniqueError(v)
 					}
 				}
 
@@ -155,6 +158,45 @@ func (tx *Tx) updateIndices(tv *typeVersion, pk []byte, ov, v reflect.Value) err
 	return nil
 }
 
+// uniqueError returns an ErrUnique ConstraintError for the values of the
+// fields of unique index idx in rv.
+func (idx *index) uniqueError(rv reflect.Value) error {
+	var values []any
+	for _, f := range idx.Fields {
+		values = append(values, rv.FieldByIndex(f.structField.Index).Interface())
+	}
+	return idx.constraintError(values)
+}
+
+// uniqueKeyError is like uniqueError, but takes the values from packed index
+// key buf.
+func (idx *index) uniqueKeyError(buf []byte) error {
+	var values []any
+	_, keys, err := idx.parseKey(buf, true, false)
+	if err == nil {
+		for i, k := range keys {
+			v := reflect.New(reflect.TypeOf(idx.Fields[i].Type.zeroKey())).Elem()
+			parsePK(v, k) // Ignore error, value is only informational.
+			values = append(values, v.Interface())
+		}
+	}
+	return idx.constraintError(values)
+}
+
+func (idx *index) constraintError(values []any) error {
+	var names []string
+	for _, f := range idx.Fields {
+		names = append(names, f.Name)
+	}
+	err := &ConstraintError{Err: ErrUnique, Type: idx.tv.name, Field: strings.Join(names, "+"), Index: idx.Name}
+	if len(values) == 1 {
+		err.Value = values[0]
+	} else if len(values) > 1 {
+		err.Value = values
+	}
+	return err
+}
+
 func (tx *Tx) checkReferences(tv *typeVersion, pk []byte, ov, rv reflect.Value) error {
 	for _, f := range tv.Fields {
 		if len(f.References) == 0 {
@@ -174,7 +216,7 @@ func (tx *Tx) checkReferences(tv *typeVersion, pk []byte, ov, rv reflect.Value)
 				return err
 			}
 			if rb.Get(k) == nil {
-				return fmt.Errorf("%w: value %v from %q to %q", ErrReference, frv.Interface(), tv.name+"."+f.Name, name)
+				return &ConstraintError{Err: ErrReference, Type: tv.name, Field: f.Name, Value: frv.Interface(), Referenced: name}
 			}
 		}
 	}
@@ -229,7 +271,9 @@ func (tx *Tx) Get(values ...any) error {
 
 // Delete removes values by their primary key from the database. Each value
 // must be a struct or pointer to a struct. Indices are automatically updated
-// and referential integrity is maintained.
+// and referential integrity is maintained. Records referencing a deleted
+// record through a "ref" struct tag with action "cascade" are deleted as well,
+// and with action "setzero" have their referencing field set to the zero value.
 //
 // ErrAbsent is returned if the record does not exist.
 // ErrReference is returned if another record still references this record.
@@ -272,35 +316,176 @@ func (tx *Tx) Delete(values ...any) error {
 	return nil
 }
 
-func (tx *Tx) delete(rb *bolt.Bucket, st storeType, k []byte, rov reflect.Value) (rerr error) {
-	// Check that anyone referencing this type does not reference this record.
+func (tx *Tx) delete(rb *bolt.Bucket, st storeType, k []byte, rov reflect.Value) error {
+	return tx.deleteCascade(rb, st, k, rov, map[recordKey]struct{}{})
+}
+
+// recordKey identifies a record by type name and packed primary key. Used to
+// detect cycles when cascading deletes.
+type recordKey struct {
+	typeName string
+	pk       string
+}
+
+// deleteCascade deletes a record. Records referencing it through fields with a
+// "cascade" or "setzero" action are deleted or updated first. Records in
+// deleting are already being deleted, they are ignored when looking for
+// references.
+func (tx *Tx) deleteCascade(rb *bolt.Bucket, st storeType, k []byte, rov reflect.Value, deleting map[recordKey]struct{}) (rerr error) {
+	deleting[recordKey{st.Name, string(k)}] = struct{}{}
+
+	h := tx.db.hooks[st.Type]
+	if h != nil && h.beforeDelete != nil {
+		if err := tx.callHook("before delete", st, func() error { return h.beforeDelete(tx, rov) }); err != nil {
+			return err
+		}
+	}
+
+	// Gather the records that reference this record, by the index of the referencing
+	// type.
+	var refs [][][]byte
+	var haveActions bool
 	for _, refBy := range st.Current.referencedBy {
-		if ib, err := tx.indexBucket(refBy); err != nil {
+		pks, err := tx.referencingKeys(refBy, rov.Field(0), deleting)
+		if err != nil {
 			return err
-		} else {
-			tx.stats.Index.Cursor++
-			if xk, _ := ib.Cursor().Seek(k); xk != nil && bytes.HasPrefix(xk, k) {
-				return fmt.Errorf("%w: index %q", ErrReference, refBy.Name)
+		}
+		// Check that anyone referencing this type without an action does not
+		// reference this record.
+		action := refBy.Fields[0].OnDelete[st.Name]
+		if len(pks) > 0 && action == "" {
+			return &ConstraintError{Err: ErrReference, Type: refBy.tv.name, Field: refBy.Fields[0].Name, Index: refBy.Name, Value: rov.Field(0).Interface(), Referenced: st.Name}
+		}
+		haveActions = haveActions || len(pks) > 0
+		refs = append(refs, pks)
+	}
+
+	defer tx.markError(&rerr)
+
+	if haveActions {
+		for i, refBy := range st.Current.referencedBy {
+			if len(refs[i]) == 0 {
+				continue
 			}
+			if err := tx.deleteReferencing(st, refBy, refs[i], deleting); err != nil {
+				return err
+			}
+		}
+	}
+
+	if haveActions || len(tx.db.hooks) > 0 {
+		// Hooks or setzero actions on self-referencing types may have modified or
+		// removed the record, and we need the stored value for removing index keys.
+		tx.stats.Records.Get++
+		bv := rb.Get(k)
+		if bv == nil {
+			return nil
+		}
+		var err error
+		rov, err = st.parseNew(k, bv)
+		if err != nil {
+			return fmt.Errorf("parsing current value: %w", err)
 		}
 	}
 
 	// Delete value from indices.
-	defer tx.markError(&rerr)
 	if err := tx.updateIndices(st.Current, k, rov, reflect.Value{}); err != nil {
 		return fmt.Errorf("removing from indices: %w", err)
 	}
 
 	tx.stats.Records.Delete++
 	tx.bucketReseek(rb)
-	return rb.Delete(k)
+	if err := rb.Delete(k); err != nil {
+		return err
+	}
+	if h != nil && h.afterDelete != nil {
+		return tx.callHook("after delete", st, func() error { return h.afterDelete(tx, rov) })
+	}
+	return nil
+}
+
+// referencingKeys returns the primary keys of records that reference pkv
+// through index refBy, skipping records that are being deleted.
+func (tx *Tx) referencingKeys(refBy *index, pkv reflect.Value, deleting map[recordKey]struct{}) ([][]byte, error) {
+	ib, err := tx.indexBucket(refBy)
+	if err != nil {
+		return nil, err
+	}
+	bufs, err := packIndexKey(pkv)
+	if err != nil {
+		return nil, err
+	}
+	pre := bufs[0]
+	var pks [][]byte
+	tx.stats.Index.Cursor++
+	c := ib.Cursor()
+	for xk, _ := c.Seek(pre); xk != nil && bytes.HasPrefix(xk, pre); xk, _ = c.Next() {
+		tx.stats.Index.Cursor++
+		pk := xk[len(pre):]
+		if _, ok := deleting[recordKey{refBy.tv.name, string(pk)}]; ok {
+			continue
+		}
+		pks = append(pks, append([]byte{}, pk...))
+	}
+	return pks, nil
+}
+
+// deleteReferencing executes the "cascade" or "setzero" action of index refBy
+// for records with primary keys pks that reference a record of st that is being
+// deleted. Records are fetched again, earlier actions may have changed or
+// removed them.
+func (tx *Tx) deleteReferencing(st storeType, refBy *index, pks [][]byte, deleting map[recordKey]struct{}) error {
+	rst := tx.db.typeNames[refBy.tv.name]
+	rrb, err := tx.recordsBucket(rst.Name, rst.Current.fillPercent)
+	if err != nil {
+		return err
+	}
+	f := refBy.Fields[0]
+	action := f.OnDelete[st.Name]
+	for _, pk := range pks {
+		if _, ok := deleting[recordKey{rst.Name, string(pk)}]; ok {
+			continue
+		}
+		tx.stats.Records.Get++
+		bv := rrb.Get(pk)
+		if bv == nil {
+			continue
+		}
+		rov, err := rst.parseNew(pk, bv)
+		if err != nil {
+			return fmt.Errorf("parsing referencing record: %w", err)
+		}
+		switch action {
+		case "cascade":
+			tx.stats.Delete++
+			if err := tx.deleteCascade(rrb, rst, pk, rov, deleting); err != nil {
+				return fmt.Errorf("cascading delete to %s: %w", rst.Name, err)
+			}
+		case "setzero":
+			rv := reflect.New(rst.Type).Elem()
+			rv.Set(rov)
+			frv := rv.FieldByIndex(f.structField.Index)
+			frv.Set(reflect.Zero(frv.Type()))
+			tx.stats.Update++
+			if err := tx.update(rrb, rst, rv, rov, pk); err != nil {
+				return fmt.Errorf("setting reference to zero in %s: %w", rst.Name, err)
+			}
+		default:
+			return fmt.Errorf("internal error: unknown ref action %q", action)
+		}
+	}
+	return nil
 }
 
 // Update updates records represented by values by their primary keys into the
 // database. Each value must be a pointer to a struct. Indices are
 // automatically updated.
 //
+// For types with a "version" field, the version of each value must match the
+// stored record, and is incremented if the record changes.
+//
 // ErrAbsent is returned if the record does not exist.
+// ErrVersion is returned if the version field does not match the stored record.
 func (tx *Tx) Update(values ...any) error {
 	if err := tx.error(); err != nil {
 		return err
@@ -351,7 +536,7 @@ func (tx *Tx) Insert(values ...any) error {
 		}
 
 		// todo optimize: should track per field whether it (or a child) has a default value, and only applyDefault if so.
-		if err := st.Current.applyDefault(rv); err != nil {
+		if err := st.Current.applyDefault(rv, tx.db.now); err != nil {
 			return err
 		}
 
@@ -362,6 +547,163 @@ func (tx *Tx) Insert(values ...any) error {
 	return nil
 }
 
+// Upsert inserts or updates values by their primary key. Each value must be a
+// pointer to a struct. If the primary key is zero, or no record with the primary
+// key exists, the value is inserted as with Insert. Otherwise the existing record
+// is replaced by the value, as with Update.
+//
+// For each value, inserted indicates whether it was inserted (true) or updated
+// (false).
+func (tx *Tx) Upsert(values ...any) ([]bool, error) {
+	if err := tx.error(); err != nil {
+		return nil, err
+	}
+
+	inserted := make([]bool, 0, len(values))
+	for _, value := range values {
+		rv, err := tx.structptr(value)
+		if err != nil {
+			return inserted, err
+		}
+		st, err := tx.db.storeType(rv.Type())
+		if err != nil {
+			return inserted, err
+		}
+		exists, err := tx.exists(st, rv)
+		if err != nil {
+			return inserted, err
+		}
+		if exists {
+			tx.stats.Update++
+			err = tx.put(st, rv, false)
+		} else {
+			tx.stats.Insert++
+			if err := st.Current.applyDefault(rv, tx.db.now); err != nil {
+				return inserted, err
+			}
+			err = tx.put(st, rv, true)
+		}
+		if err != nil {
+			return inserted, err
+		}
+		inserted = append(inserted, !exists)
+	}
+	return inserted, nil
+}
+
+// InsertOrIgnore inserts values as new records into the database, like Insert,
+// but skips values for which a record with the same primary key already
+// exists. If uniqueIndex is not empty, it must be the name of a unique index of
+// the type of each value, and values that would violate that unique constraint
+// are skipped as well. Default values are taken into account when checking for
+// conflicts, but are only set on inserted values.
+//
+// For each value, inserted indicates whether it was inserted (true) or skipped
+// (false).
+func (tx *Tx) InsertOrIgnore(uniqueIndex string, values ...any) ([]bool, error) {
+	if err := tx.error(); err != nil {
+		return nil, err
+	}
+
+	inserted := make([]bool, 0, len(values))
+	for _, value := range values {
+		rv, err := tx.structptr(value)
+		if err != nil {
+			return inserted, err
+		}
+		st, err := tx.db.storeType(rv.Type())
+		if err != nil {
+			return inserted, err
+		}
+		var idx *index
+		if uniqueIndex != "" {
+			idx = st.Current.Indices[uniqueIndex]
+			if idx == nil || !idx.Unique {
+				return inserted, fmt.Errorf("%w: no unique index %q for type %q", ErrParam, uniqueIndex, st.Name)
+			}
+		}
+
+		// Use the same time for the conflict check and the insert.
+		tm := tx.db.now()
+		now := func() time.Time { return tm }
+
+		// Defaults are not applied to the primary key.
+		conflict, err := tx.exists(st, rv)
+		if err != nil {
+			return inserted, err
+		}
+		if !conflict && idx != nil {
+			// Check a copy with defaults for the index fields, skipped values are not
+			// modified. Index fields are top-level, setting their default does not modify
+			// data shared with rv.
+			crv := reflect.New(rv.Type()).Elem()
+			crv.Set(rv)
+			for _, f := range idx.Fields {
+				if err := f.applyDefault(crv.FieldByIndex(f.structField.Index), now); err != nil {
+					return inserted, err
+				}
+			}
+			conflict, err = tx.uniqueExists(idx, crv)
+			if err != nil {
+				return inserted, err
+			}
+		}
+		if conflict {
+			inserted = append(inserted, false)
+			continue
+		}
+
+		if err := st.Current.applyDefault(rv, now); err != nil {
+			return inserted, err
+		}
+		tx.stats.Insert++
+		if err := tx.put(st, rv, true); err != nil {
+			return inserted, err
+		}
+		inserted = append(inserted, true)
+	}
+	return inserted, nil
+}
+
+// exists returns whether a record with the primary key of rv exists. A zero
+// primary key never exists.
+func (tx *Tx) exists(st storeType, rv reflect.Value) (bool, error) {
+	krv := rv.FieldByIndex(st.Current.Fields[0].structField.Index)
+	if krv.IsZero() {
+		return false, nil
+	}
+	rb, err := tx.recordsBucket(st.Current.name, st.Current.fillPercent)
+	if err != nil {
+		return false, err
+	}
+	k, err := packPK(krv)
+	if err != nil {
+		return false, err
+	}
+	tx.stats.Records.Get++
+	return rb.Get(k) != nil, nil
+}
+
+// uniqueExists returns whether a record exists with the same values as rv for
+// the fields of unique index idx.
+func (tx *Tx) uniqueExists(idx *index, rv reflect.Value) (bool, error) {
+	ib, err := tx.indexBucket(idx)
+	if err != nil {
+		return false, err
+	}
+	ikl, err := idx.packKey(rv, nil)
+	if err != nil {
+		return false, err
+	}
+	for _, ik := range ikl {
+		tx.stats.Index.Cursor++
+		if xk, _ := ib.Cursor().Seek(ik.pre); xk != nil && bytes.HasPrefix(xk, ik.pre) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (tx *Tx) put(st storeType, rv reflect.Value, insert bool) error {
 	rb, err := tx.recordsBucket(st.Current.name, st.Current.fillPercent)
 	if err != nil {
@@ -375,7 +717,7 @@ func (tx *Tx) put(st storeType, rv reflect.Value, insert bool) error {
 		tx.stats.Records.Get++
 		bv := rb.Get(k)
 		if bv != nil {
-			return fmt.Errorf("%w: record already exists", ErrUnique)
+			return &ConstraintError{Err: ErrUnique, Type: st.Name, Field: st.Current.Fields[0].Name, Value: krv.Interface()}
 		}
 		err := tx.insert(rb, st, rv, krv, k)
 		if err != nil && seq {
@@ -398,6 +740,29 @@ func (tx *Tx) put(st storeType, rv reflect.Value, insert bool) error {
 }
 
 func (tx *Tx) insert(rb *bolt.Bucket, st storeType, rv, krv reflect.Value, k []byte) (rerr error) {
+	restore := st.Current.saveAutoFields(rv)
+	defer func() {
+		if rerr != nil {
+			restore()
+		}
+	}()
+	st.Current.setAutoTimes(rv, reflect.Value{}, tx.db.now)
+	st.Current.initVersion(rv)
+	if err := tx.assignSeqs(st.Current, rv, true); err != nil {
+		return err
+	}
+	h := tx.db.hooks[st.Type]
+	if h != nil && h.beforeInsert != nil {
+		if err := tx.callHook("before insert", st, func() error { return h.beforeInsert(tx, rv) }); err != nil {
+			return err
+		}
+		if err := tx.checkHookPK("before insert", st, rv, k); err != nil {
+			return err
+		}
+	}
+	if err := st.validate(rv); err != nil {
+		return err
+	}
 	v, err := st.pack(rv)
 	if err != nil {
 		return err
@@ -415,14 +780,46 @@ func (tx *Tx) insert(rb *bolt.Bucket, st storeType, rv, krv reflect.Value, k []b
 		return err
 	}
 	rv.Field(0).Set(krv)
+	if h != nil && h.afterInsert != nil {
+		return tx.callHook("after insert", st, func() error { return h.afterInsert(tx, rv) })
+	}
 	return nil
 }
 
 func (tx *Tx) update(rb *bolt.Bucket, st storeType, rv, rov reflect.Value, k []byte) (rerr error) {
+	restore := st.Current.saveAutoFields(rv)
+	defer func() {
+		if rerr != nil {
+			restore()
+		}
+	}()
+	if err := st.Current.checkVersion(rv, rov); err != nil {
+		return err
+	}
+	st.Current.keepCreateTimes(rv, rov)
 	if st.Current.equal(rov, rv) {
 		return nil
 	}
+	st.Current.setAutoTimes(rv, rov, tx.db.now)
+	if err := st.Current.incrementVersion(rv); err != nil {
+		return err
+	}
+	if err := tx.assignSeqs(st.Current, rv, false); err != nil {
+		return err
+	}
 
+	h := tx.db.hooks[st.Type]
+	if h != nil && h.beforeUpdate != nil {
+		if err := tx.callHook("before update", st, func() error { return h.beforeUpdate(tx, rov, rv) }); err != nil {
+			return err
+		}
+		if err := tx.checkHookPK("before update", st, rv, k); err != nil {
+			return err
+		}
+	}
+	if err := st.validate(rv); err != nil {
+		return err
+	}
 	v, err := st.pack(rv)
 	if err != nil {
 		return err
@@ -436,7 +833,93 @@ func (tx *Tx) update(rb *bolt.Bucket, st storeType, rv, rov reflect.Value, k []b
 	}
 	tx.stats.Records.Put++
 	tx.bucketReseek(rb)
-	return rb.Put(k, v)
+	if err := rb.Put(k, v); err != nil {
+		return err
+	}
+	if h != nil && h.afterUpdate != nil {
+		return tx.callHook("after update", st, func() error { return h.afterUpdate(tx, rov, rv) })
+	}
+	return nil
+}
+
+// saveAutoFields returns a function that restores the fields of rv that insert
+// and update set automatically: "autocreate" and "autoupdate" times, "version"
+// and "seq" or "modseq" fields. Called when the write fails, so a value can be
+// corrected and written again.
+func (tv *typeVersion) saveAutoFields(rv reflect.Value) func() {
+	type saved struct {
+		fv, v reflect.Value
+	}
+	var l []saved
+	for _, f := range tv.Fields[1:] {
+		if f.Auto == "" && !f.Version && f.Seq == "" {
+			continue
+		}
+		fv := rv.FieldByIndex(f.structField.Index)
+		v := reflect.New(fv.Type()).Elem()
+		v.Set(fv)
+		l = append(l, saved{fv, v})
+	}
+	return func() {
+		for _, s := range l {
+			s.fv.Set(s.v)
+		}
+	}
+}
+
+// initVersion sets a zero "version" field to 1 for a new record.
+func (tv *typeVersion) initVersion(rv reflect.Value) {
+	for _, f := range tv.Fields[1:] {
+		if !f.Version {
+			continue
+		}
+		fv := rv.FieldByIndex(f.structField.Index)
+		if !fv.IsZero() {
+			continue
+		}
+		if fv.CanInt() {
+			fv.SetInt(1)
+		} else {
+			fv.SetUint(1)
+		}
+	}
+}
+
+// checkVersion returns an ErrVersion ConstraintError if the "version" field of
+// rv is different from the stored record rov.
+func (tv *typeVersion) checkVersion(rv, rov reflect.Value) error {
+	for _, f := range tv.Fields[1:] {
+		if !f.Version {
+			continue
+		}
+		ofv := rov.FieldByIndex(f.structField.Index)
+		if rv.FieldByIndex(f.structField.Index).Interface() != ofv.Interface() {
+			return &ConstraintError{Err: ErrVersion, Type: tv.name, Field: f.Name, Value: ofv.Interface()}
+		}
+	}
+	return nil
+}
+
+// incrementVersion increments the "version" field of rv.
+func (tv *typeVersion) incrementVersion(rv reflect.Value) error {
+	for _, f := range tv.Fields[1:] {
+		if !f.Version {
+			continue
+		}
+		fv := rv.FieldByIndex(f.structField.Index)
+		if fv.CanInt() {
+			if fv.Int() == math.MaxInt64 || fv.OverflowInt(fv.Int()+1) {
+				return fmt.Errorf("%w: next version for field %q does not fit in type", ErrSeq, f.Name)
+			}
+			fv.SetInt(fv.Int() + 1)
+		} else {
+			if fv.Uint() == math.MaxUint64 || fv.OverflowUint(fv.Uint()+1) {
+				return fmt.Errorf("%w: next version for field %q does not fit in type", ErrSeq, f.Name)
+			}
+			fv.SetUint(fv.Uint() + 1)
+		}
+	}
+	return nil
 }
 
 // bucketReseek marks queries as needing a reseek on their cursor due to changes to
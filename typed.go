@@ -0,0 +1,98 @@
+package bstore
+
+import (
+	"fmt"
+	"reflect"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Get returns the record of type T with primary key id from transaction tx.
+// The type of id must be the type of T's primary key.
+//
+// ErrAbsent is returned if the record does not exist.
+func Get[T any](tx *Tx, id any) (T, error) {
+	var v T
+	st, rb, pk, err := typedKey[T](tx, id)
+	if err != nil {
+		return v, err
+	}
+	tx.stats.Get++
+	tx.stats.Records.Get++
+	bv := rb.Get(pk)
+	if bv == nil {
+		return v, ErrAbsent
+	}
+	if err := st.parseFull(reflect.ValueOf(&v).Elem(), pk, bv); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// Exists returns whether a record of type T with primary key id exists in
+// transaction tx. The type of id must be the type of T's primary key.
+func Exists[T any](tx *Tx, id any) (bool, error) {
+	_, rb, pk, err := typedKey[T](tx, id)
+	if err != nil {
+		return false, err
+	}
+	tx.stats.Records.Get++
+	return rb.Get(pk) != nil, nil
+}
+
+// DeleteID removes the record of type T with primary key id, like Tx.Delete.
+// The type of id must be the type of T's primary key.
+//
+// ErrAbsent is returned if the record does not exist.
+// ErrReference is returned if another record still references this record.
+func DeleteID[T any](tx *Tx, id any) error {
+	st, rb, pk, err := typedKey[T](tx, id)
+	if err != nil {
+		return err
+	}
+	tx.stats.Delete++
+	tx.stats.Records.Get++
+	bv := rb.Get(pk)
+	if bv == nil {
+		return ErrAbsent
+	}
+	rov, err := st.parseNew(pk, bv)
+	if err != nil {
+		return fmt.Errorf("parsing current value: %w", err)
+	}
+	return tx.delete(rb, st, pk, rov)
+}
+
+// typedKey returns the storeType for T, its records bucket and the packed
+// primary key id, checking that id has the type of T's primary key.
+func typedKey[T any](tx *Tx, id any) (storeType, *bolt.Bucket, []byte, error) {
+	if err := tx.error(); err != nil {
+		return storeType{}, nil, nil, err
+	}
+	var zero T
+	t := reflect.TypeOf(zero)
+	if t == nil || t.Kind() != reflect.Struct {
+		return storeType{}, nil, nil, fmt.Errorf("%w: type must be struct, not pointer or other type", ErrType)
+	}
+	st, err := tx.db.storeType(t)
+	if err != nil {
+		return storeType{}, nil, nil, err
+	}
+	pkType := st.Current.Fields[0].structField.Type
+	kv := reflect.ValueOf(id)
+	if !kv.IsValid() || kv.Type() != pkType {
+		return storeType{}, nil, nil, fmt.Errorf("%w: id type was %T, must be %s", ErrParam, id, pkType)
+	}
+	if kv.IsZero() {
+		return storeType{}, nil, nil, fmt.Errorf("%w: primary key can not be zero value", ErrParam)
+	}
+	pk, err := packPK(kv)
+	if err != nil {
+		return storeType{}, nil, nil, err
+	}
+	rb, err := tx.recordsBucket(st.Current.name, st.Current.fillPercent)
+	if err != nil {
+		return storeType{}, nil, nil, err
+	}
+	return st, rb, pk, nil
+}
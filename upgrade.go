@@ -0,0 +1,115 @@
+package bstore
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// UpgradeResult describes the changes made by UpgradeRecords.
+type UpgradeResult struct {
+	Version   uint32         // Current type version, all records now use it.
+	Rewritten map[uint32]int // Number of records rewritten, per old type version.
+	Removed   []uint32       // Old type versions removed from the database, in order.
+}
+
+// UpgradeRecords rewrites all records of registered type typeName that are
+// stored with an older type version (schema) to the current type version, and
+// removes the older type versions from the database. Old type versions are
+// otherwise kept forever, they are needed to parse records stored with them.
+// The indices are not changed, the field values of the records stay the same.
+func (tx *Tx) UpgradeRecords(typeName string) (UpgradeResult, error) {
+	if err := tx.error(); err != nil {
+		return UpgradeResult{}, err
+	}
+	st, ok := tx.db.typeNames[typeName]
+	if !ok {
+		return UpgradeResult{}, fmt.Errorf("%w: type %q not registered", ErrType, typeName)
+	}
+
+	rb, err := tx.recordsBucket(st.Name, st.Current.fillPercent)
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+	r := UpgradeResult{Version: st.Current.Version, Rewritten: map[uint32]int{}}
+	type record struct{ k, v []byte }
+	var records []record
+	ctxDone := tx.ctx.Done()
+	err = rb.ForEach(func(bk, bv []byte) error {
+		tx.stats.Records.Cursor++
+
+		select {
+		case <-ctxDone:
+			return tx.ctx.Err()
+		default:
+		}
+
+		version, n := binary.Uvarint(bv)
+		if n <= 0 {
+			return fmt.Errorf("%w: reading type version of record", ErrStore)
+		}
+		if uint32(version) == st.Current.Version {
+			return nil
+		}
+		rv, err := st.parseNew(bk, bv)
+		if err != nil {
+			return fmt.Errorf("parsing record with old type version: %w", err)
+		}
+		v, err := st.pack(rv)
+		if err != nil {
+			return fmt.Errorf("packing record %v: %w", rv.Field(0).Interface(), err)
+		}
+		records = append(records, record{append([]byte{}, bk...), v})
+		r.Rewritten[uint32(version)]++
+		return nil
+	})
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+	for _, rec := range records {
+		tx.stats.Records.Put++
+		if err := rb.Put(rec.k, rec.v); err != nil {
+			return UpgradeResult{}, fmt.Errorf("%w: storing upgraded record: %s", ErrStore, err)
+		}
+	}
+	tx.bucketReseek(rb)
+
+	// No records reference old type versions anymore. We keep them in st.Versions,
+	// for transactions that may still be reading old records.
+	tb, err := tx.bucket(bucketKey{st.Name, "types"})
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+	err = tb.ForEach(func(bk, bv []byte) error {
+		if len(bk) != 4 {
+			return fmt.Errorf("%w: bad type version key %x", ErrStore, bk)
+		}
+		if version := binary.BigEndian.Uint32(bk); version != st.Current.Version {
+			r.Removed = append(r.Removed, version)
+		}
+		return nil
+	})
+	if err != nil {
+		return UpgradeResult{}, err
+	}
+	for _, version := range r.Removed {
+		// note: we don't track stats for types operations.
+		if err := tb.Delete(binary.BigEndian.AppendUint32(nil, version)); err != nil {
+			return UpgradeResult{}, fmt.Errorf("%w: removing type version %d: %s", ErrStore, version, err)
+		}
+	}
+	return r, nil
+}
+
+// UpgradeRecords calls UpgradeRecords on a new writable Tx.
+func (db *DB) UpgradeRecords(ctx context.Context, typeName string) (r UpgradeResult, rerr error) {
+	rerr = db.Write(ctx, func(tx *Tx) error {
+		var err error
+		r, err = tx.UpgradeRecords(typeName)
+		return err
+	})
+	if rerr != nil {
+		return UpgradeResult{}, rerr
+	}
+	return r, nil
+}
@@ -0,0 +1,71 @@
+package bstore
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Validator can be implemented by stored types, to validate values before they
+// are written. BstoreValidate is called, with a pointer receiver, when a value
+// is inserted or updated (including through Query.Update* and BulkLoad), after
+// default values have been applied and before unique, reference and nonzero
+// constraints are checked. If it returns an error, the operation is aborted
+// without modifying the database, and the error is returned, wrapped. Updates
+// that do not change a record do not call BstoreValidate.
+//
+// If Options.RegisterValidate is set, BstoreValidate is also called for all
+// existing records of types with a changed schema during Open/Register.
+type Validator interface {
+	BstoreValidate() error
+}
+
+var validatorType = reflect.TypeFor[Validator]()
+
+// validate calls BstoreValidate on struct value rv if its type implements
+// Validator.
+func (st storeType) validate(rv reflect.Value) error {
+	if !reflect.PointerTo(rv.Type()).Implements(validatorType) {
+		return nil
+	}
+	if !rv.CanAddr() {
+		nrv := reflect.New(rv.Type()).Elem()
+		nrv.Set(rv)
+		rv = nrv
+	}
+	if err := rv.Addr().Interface().(Validator).BstoreValidate(); err != nil {
+		return fmt.Errorf("validating %s: %w", st.Name, err)
+	}
+	return nil
+}
+
+// validateRecords calls BstoreValidate on all records of st.
+func (tx *Tx) validateRecords(st storeType) error {
+	if !reflect.PointerTo(st.Type).Implements(validatorType) {
+		return nil
+	}
+	rb, err := tx.recordsBucket(st.Current.name, st.Current.fillPercent)
+	if err != nil {
+		return err
+	}
+
+	ctxDone := tx.ctx.Done()
+
+	return rb.ForEach(func(bk, bv []byte) error {
+		tx.stats.Records.Cursor++
+
+		select {
+		case <-ctxDone:
+			return tx.ctx.Err()
+		default:
+		}
+
+		rv, err := st.parseNew(bk, bv)
+		if err != nil {
+			return err
+		}
+		if err := st.validate(rv); err != nil {
+			return fmt.Errorf("existing record %v: %w", rv.Field(0).Interface(), err)
+		}
+		return nil
+	})
+}